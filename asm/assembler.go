@@ -0,0 +1,274 @@
+// Package asm implements the text assembly language of JSM.
+//
+// A program is written one instruction per line:
+//
+//	; computes the n-th Fibonacci number
+//	fib: lda 0
+//	     lt 2
+//	     jt init
+//	     ...
+//	     call fib, 1 ; fib(n-1)
+//
+// An instruction consists of an optional label followed by a colon,
+// a mnemonic and comma-separated immediates. An immediate is either
+// a JSON value or a bare word, which denotes a string such as a label.
+// A semicolon starts a comment that extends to the end of the line.
+// A comment following an instruction becomes the comment of the instruction.
+// A label may also be placed on its own line, in which case it labels
+// the next instruction.
+package asm
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/plenluno/jsm"
+)
+
+// SyntaxError describes a syntax error in a program.
+type SyntaxError struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *SyntaxError) Error() string {
+	return fmt.Sprintf("%d:%d: %s", e.Line, e.Column, e.Msg)
+}
+
+// Assemble parses a program written in the assembly language.
+func Assemble(r io.Reader) ([]jsm.Instruction, error) {
+	program := []jsm.Instruction{}
+	var label string
+	var labelLine, labelColumn int
+
+	s := bufio.NewScanner(r)
+	for n := 1; s.Scan(); n++ {
+		l := &line{text: s.Text(), number: n}
+		inst, ok, err := l.parse()
+		if err != nil {
+			return nil, err
+		}
+
+		if inst.Label != "" {
+			if label != "" {
+				return nil, l.errorAt(0, "instruction has multiple labels")
+			}
+			label = inst.Label
+			labelLine, labelColumn = n, 1
+		}
+
+		if ok {
+			inst.Label = label
+			program = append(program, inst)
+			label = ""
+		}
+	}
+	if err := s.Err(); err != nil {
+		return nil, errors.Wrap(err, "failed to read program")
+	}
+
+	if label != "" {
+		return nil, &SyntaxError{Line: labelLine, Column: labelColumn, Msg: "label without instruction"}
+	}
+	return program, nil
+}
+
+// AssembleString parses a program written in the assembly language.
+func AssembleString(s string) ([]jsm.Instruction, error) {
+	return Assemble(strings.NewReader(s))
+}
+
+type line struct {
+	text   string
+	number int
+	pos    int
+}
+
+// parse parses the line and reports whether it contains an instruction.
+// The returned instruction may only have a label even if it is not an instruction.
+func (l *line) parse() (jsm.Instruction, bool, error) {
+	var inst jsm.Instruction
+
+	l.skipSpaces()
+	if l.atEnd() {
+		return inst, false, nil
+	}
+
+	start := l.pos
+	var word string
+	var err error
+	if l.peek() == '"' {
+		word, err = l.quoted()
+	} else {
+		word, err = l.word()
+	}
+	if err != nil {
+		return inst, false, err
+	}
+
+	l.skipSpaces()
+	if l.peek() == ':' {
+		if word == "" {
+			return inst, false, l.errorAt(start, "empty label")
+		}
+		inst.Label = word
+		l.pos++
+
+		l.skipSpaces()
+		if l.atEnd() {
+			return inst, false, nil
+		}
+
+		start = l.pos
+		if word, err = l.word(); err != nil {
+			return inst, false, err
+		}
+		l.skipSpaces()
+	} else if l.text[start] == '"' {
+		return inst, false, l.errorAt(l.pos, "expected ':'")
+	}
+
+	inst.Mnemonic = jsm.Mnemonic(word)
+	if !l.atEnd() {
+		if inst.Immediates, err = l.immediates(); err != nil {
+			return inst, false, err
+		}
+	}
+
+	if l.pos < len(l.text) {
+		inst.Comment = strings.TrimSpace(l.text[l.pos+1:])
+	}
+	return inst, true, nil
+}
+
+func (l *line) immediates() ([]jsm.Value, error) {
+	var imms []jsm.Value
+	for {
+		imm, err := l.immediate()
+		if err != nil {
+			return nil, err
+		}
+		imms = append(imms, imm)
+
+		l.skipSpaces()
+		if l.atEnd() {
+			return imms, nil
+		}
+
+		if l.peek() != ',' {
+			return nil, l.errorAt(l.pos, "expected ','")
+		}
+		l.pos++
+		l.skipSpaces()
+	}
+}
+
+func (l *line) immediate() (jsm.Value, error) {
+	if l.atEnd() {
+		return nil, l.errorAt(l.pos, "missing immediate")
+	}
+
+	if isWordStart(l.peek()) {
+		w, err := l.word()
+		if err != nil {
+			return nil, err
+		}
+
+		switch w {
+		case "true":
+			return jsm.BooleanValue(true), nil
+		case "false":
+			return jsm.BooleanValue(false), nil
+		case "null":
+			return jsm.NullValue(), nil
+		default:
+			return jsm.StringValue(w), nil
+		}
+	}
+
+	return l.json()
+}
+
+func (l *line) json() (jsm.Value, error) {
+	d := json.NewDecoder(strings.NewReader(l.text[l.pos:]))
+	var v jsm.Value
+	if err := d.Decode(&v); err != nil {
+		pos := l.pos
+		if se, ok := err.(*json.SyntaxError); ok {
+			pos += int(se.Offset) - 1
+		} else if err == io.ErrUnexpectedEOF {
+			pos = len(l.text)
+		}
+		return nil, l.errorAt(pos, "invalid immediate")
+	}
+
+	l.pos += int(d.InputOffset())
+	return v, nil
+}
+
+func (l *line) quoted() (string, error) {
+	v, err := l.json()
+	if err != nil {
+		return "", err
+	}
+	return jsm.ToString(v), nil
+}
+
+func (l *line) word() (string, error) {
+	if !isWordStart(l.peek()) {
+		if l.atEnd() {
+			return "", l.errorAt(l.pos, "missing mnemonic")
+		}
+		r, _ := utf8.DecodeRuneInString(l.text[l.pos:])
+		return "", l.errorAt(l.pos, fmt.Sprintf("unexpected %q", r))
+	}
+
+	start := l.pos
+	for l.pos < len(l.text) && isWordPart(l.text[l.pos]) {
+		l.pos++
+	}
+	return l.text[start:l.pos], nil
+}
+
+func (l *line) skipSpaces() {
+	for l.pos < len(l.text) && (l.text[l.pos] == ' ' || l.text[l.pos] == '\t') {
+		l.pos++
+	}
+}
+
+func (l *line) atEnd() bool {
+	return l.pos >= len(l.text) || l.text[l.pos] == ';'
+}
+
+func (l *line) peek() byte {
+	if l.pos >= len(l.text) {
+		return 0
+	}
+	return l.text[l.pos]
+}
+
+func (l *line) errorAt(pos int, msg string) error {
+	if pos > len(l.text) {
+		pos = len(l.text)
+	}
+	return &SyntaxError{
+		Line:   l.number,
+		Column: utf8.RuneCountInString(l.text[:pos]) + 1,
+		Msg:    msg,
+	}
+}
+
+func isWordStart(c byte) bool {
+	return c == '_' || c == '$' || c == '.' ||
+		('a' <= c && c <= 'z') || ('A' <= c && c <= 'Z')
+}
+
+func isWordPart(c byte) bool {
+	return isWordStart(c) || ('0' <= c && c <= '9')
+}
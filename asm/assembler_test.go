@@ -0,0 +1,97 @@
+package asm
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/plenluno/jsm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAssemble(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := AssembleString(`; computes the n-th Fibonacci number
+fib:	lda 0
+	lt 2
+	jt init
+	lda 0
+	sub 1
+	call fib, 1 ; fib(n-1)
+	lda 0
+	sub 2
+	call fib, 1 ; fib(n-2)
+	add
+	ret 1
+
+init:
+	lda 0
+	ret 1
+`)
+	assert.NoError(err)
+
+	j, err := ioutil.ReadFile("../examples/fibonacci.json")
+	assert.NoError(err)
+
+	var fib []jsm.Instruction
+	err = json.Unmarshal(j, &fib)
+	assert.NoError(err)
+	fib[5].Comment = "fib(n-1)"
+	fib[8].Comment = "fib(n-2)"
+	assert.Equal(fib, p)
+}
+
+func TestAssembleImmediates(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := AssembleString(`"my label": push 1.5, -2, "a;b", abc, true, false, null, [1, "x"], {"k": {}}`)
+	assert.NoError(err)
+	assert.Equal([]jsm.Instruction{{
+		Label:    "my label",
+		Mnemonic: jsm.MnemonicPush,
+		Immediates: []jsm.Value{
+			1.5, -2.0, "a;b", "abc", true, false, nil,
+			[]interface{}{1.0, "x"}, map[string]interface{}{"k": map[string]interface{}{}},
+		},
+	}}, p)
+
+	p, err = AssembleString("nop;comment\n  ;\n\nret")
+	assert.NoError(err)
+	assert.Equal([]jsm.Instruction{
+		{Mnemonic: jsm.MnemonicNop, Comment: "comment"},
+		{Mnemonic: jsm.MnemonicReturn},
+	}, p)
+
+	p, err = AssembleString("")
+	assert.NoError(err)
+	assert.Equal([]jsm.Instruction{}, p)
+}
+
+func TestAssembleErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, c := range []struct {
+		src string
+		err string
+	}{
+		{"push 1 2", "1:8: expected ','"},
+		{"push 1,", "1:8: missing immediate"},
+		{"push 1,, 2", "1:8: invalid immediate"},
+		{"nop\n  push [1,", "2:11: invalid immediate"},
+		{"a:\nb: nop", "2:1: instruction has multiple labels"},
+		{"nop\nend:", "2:1: label without instruction"},
+		{"1: nop", "1:1: unexpected '1'"},
+		{"a:: nop", "1:3: unexpected ':'"},
+		{`"a" nop`, "1:5: expected ':'"},
+		{`"": nop`, "1:1: empty label"},
+		{"ä: nop", "1:1: unexpected 'ä'"},
+		{"x: push \"ä\" 1", "1:13: expected ','"},
+	} {
+		_, err := AssembleString(c.src)
+		if assert.Error(err, c.src) {
+			assert.IsType(&SyntaxError{}, err, c.src)
+			assert.Equal(c.err, err.Error(), c.src)
+		}
+	}
+}
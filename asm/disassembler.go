@@ -0,0 +1,124 @@
+package asm
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/plenluno/jsm"
+)
+
+// Disassemble writes the program in the canonical form of the assembly language.
+// Each label is placed on its own line and each instruction is indented with a tab.
+func Disassemble(w io.Writer, program []jsm.Instruction) error {
+	bw := bufio.NewWriter(w)
+	for idx, inst := range program {
+		s, err := format(&inst)
+		if err != nil {
+			return errors.Wrapf(err, "cannot disassemble instruction %d", idx)
+		}
+
+		if _, err := bw.WriteString(s); err != nil {
+			return errors.Wrap(err, "failed to write program")
+		}
+	}
+	return errors.Wrap(bw.Flush(), "failed to write program")
+}
+
+// DisassembleString returns the program in the canonical form of the assembly language.
+func DisassembleString(program []jsm.Instruction) (string, error) {
+	var buf bytes.Buffer
+	if err := Disassemble(&buf, program); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func format(inst *jsm.Instruction) (string, error) {
+	var sb strings.Builder
+
+	if inst.Label != "" {
+		label, err := formatWord(inst.Label)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(label)
+		sb.WriteString(":\n")
+	}
+
+	m := string(inst.Mnemonic)
+	if !isWord(m) {
+		return "", errors.Errorf("invalid mnemonic: %q", m)
+	}
+	sb.WriteByte('\t')
+	sb.WriteString(m)
+
+	for i, imm := range inst.Immediates {
+		if i == 0 {
+			sb.WriteByte(' ')
+		} else {
+			sb.WriteString(", ")
+		}
+
+		s, err := formatImmediate(imm)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteString(s)
+	}
+
+	if inst.Comment != "" {
+		if strings.ContainsAny(inst.Comment, "\r\n") {
+			return "", errors.New("multi-line comment")
+		}
+		sb.WriteString(" ; ")
+		sb.WriteString(inst.Comment)
+	}
+
+	sb.WriteByte('\n')
+	return sb.String(), nil
+}
+
+func formatWord(s string) (string, error) {
+	if isWord(s) && !isKeyword(s) {
+		return s, nil
+	}
+
+	data, err := json.Marshal(s)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot convert to json")
+	}
+	return string(data), nil
+}
+
+func formatImmediate(v jsm.Value) (string, error) {
+	if jsm.TypeOf(v) == jsm.TypeString {
+		return formatWord(jsm.ToString(v))
+	}
+
+	data, err := json.Marshal(v)
+	if err != nil {
+		return "", errors.Wrap(err, "cannot convert to json")
+	}
+	return string(data), nil
+}
+
+func isWord(s string) bool {
+	if s == "" || !isWordStart(s[0]) {
+		return false
+	}
+
+	for i := 1; i < len(s); i++ {
+		if !isWordPart(s[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+func isKeyword(s string) bool {
+	return s == "true" || s == "false" || s == "null"
+}
@@ -0,0 +1,83 @@
+package asm
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"math"
+	"testing"
+
+	"github.com/plenluno/jsm"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDisassemble(t *testing.T) {
+	assert := assert.New(t)
+
+	j, err := ioutil.ReadFile("../examples/sum_of_series.json")
+	assert.NoError(err)
+
+	var p []jsm.Instruction
+	err = json.Unmarshal(j, &p)
+	assert.NoError(err)
+	p[1].Comment = "i = 1"
+
+	s, err := DisassembleString(p)
+	assert.NoError(err)
+	assert.Equal(`	push 0
+	stl i, 1 ; i = 1
+loop:
+	ldl i
+	lda 0
+	le
+	jf exit
+	ldl i
+	add
+	incl i
+	jmp loop
+exit:
+	ret 1
+`, s)
+
+	p2, err := AssembleString(s)
+	assert.NoError(err)
+	assert.Equal(p, p2)
+}
+
+func TestDisassembleQuoting(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []jsm.Instruction{{
+		Label:    "a b",
+		Mnemonic: jsm.MnemonicPush,
+		Immediates: []jsm.Value{
+			"true", "x y", "", 1.5, nil,
+			[]interface{}{"a", 1.0}, map[string]interface{}{"k": false},
+		},
+	}}
+
+	s, err := DisassembleString(p)
+	assert.NoError(err)
+	assert.Equal(`"a b":
+	push "true", "x y", "", 1.5, null, ["a",1], {"k":false}
+`, s)
+
+	p2, err := AssembleString(s)
+	assert.NoError(err)
+	assert.Equal(p, p2)
+}
+
+func TestDisassembleErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := DisassembleString([]jsm.Instruction{{Mnemonic: "a b"}})
+	assert.Error(err)
+
+	_, err = DisassembleString([]jsm.Instruction{{Mnemonic: jsm.MnemonicNop, Comment: "a\nb"}})
+	assert.Error(err)
+
+	_, err = DisassembleString([]jsm.Instruction{{
+		Mnemonic:   jsm.MnemonicPush,
+		Immediates: []jsm.Value{jsm.NumberValue(math.NaN())},
+	}})
+	assert.Error(err)
+}
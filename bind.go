@@ -0,0 +1,291 @@
+package jsm
+
+import (
+	"context"
+	"math"
+	"reflect"
+
+	"github.com/pkg/errors"
+)
+
+var (
+	contextType = reflect.TypeOf((*context.Context)(nil)).Elem()
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// binding is an instruction that calls a Go function.
+type binding struct {
+	fn         reflect.Value
+	params     []reflect.Type
+	variadic   bool
+	hasContext bool
+	hasResult  bool
+	hasError   bool
+}
+
+func newBinding(fn interface{}) (*binding, error) {
+	val := reflect.ValueOf(fn)
+	if val.Kind() != reflect.Func || val.IsNil() {
+		return nil, errors.New("not a function")
+	}
+
+	t := val.Type()
+	b := &binding{fn: val, variadic: t.IsVariadic()}
+	for i := 0; i < t.NumIn(); i++ {
+		if i == 0 && t.In(i) == contextType {
+			b.hasContext = true
+			continue
+		}
+		b.params = append(b.params, t.In(i))
+	}
+
+	switch t.NumOut() {
+	case 0:
+	case 1:
+		if t.Out(0) == errorType {
+			b.hasError = true
+		} else {
+			b.hasResult = true
+		}
+	case 2:
+		if t.Out(1) != errorType {
+			return nil, errors.New("second result is not an error")
+		}
+		b.hasResult = true
+		b.hasError = true
+	default:
+		return nil, errors.New("too many results")
+	}
+	return b, nil
+}
+
+// arity returns the number of operands taken by the instruction with the given immediates.
+func (b *binding) arity(imms []Value) int {
+	if b.variadic && len(imms) > 0 {
+		return ToInteger(imms[0])
+	}
+
+	if b.variadic {
+		return len(b.params) - 1
+	}
+	return len(b.params)
+}
+
+func (b *binding) effect(imms []Value) (int, int, error) {
+	n := b.arity(imms)
+	if n < 0 {
+		return -1, 0, errors.New("invalid count")
+	}
+
+	if b.hasResult {
+		return n, 1, nil
+	}
+	return n, 0, nil
+}
+
+func (b *binding) process(ctx context.Context, imms []Value) error {
+	vs, err := doMultiPop(ctx, b.arity(imms))
+	if err != nil {
+		return err
+	}
+
+	var in []reflect.Value
+	if b.hasContext {
+		in = append(in, reflect.ValueOf(ctx))
+	}
+
+	for i, v := range vs {
+		var t reflect.Type
+		if b.variadic && i >= len(b.params)-1 {
+			t = b.params[len(b.params)-1].Elem()
+		} else {
+			t = b.params[i]
+		}
+
+		arg, err := toGo(v, t)
+		if err != nil {
+			return err
+		}
+		in = append(in, arg)
+	}
+
+	out := b.fn.Call(in)
+	if b.hasError {
+		if err, _ := out[len(out)-1].Interface().(error); err != nil {
+			return err
+		}
+	}
+
+	if b.hasResult {
+		if err := doPush(ctx, fromGo(out[0])); err != nil {
+			return err
+		}
+	}
+
+	GetProgramCounter(ctx).Increment()
+	return nil
+}
+
+func (b *binding) preprocess(ctx context.Context, imms []Value) ([]Value, error) {
+	if !b.variadic {
+		return noImmediate(ctx, imms)
+	}
+
+	vs, err := atMostOneInteger(ctx, imms)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(vs) > 0 && ToInteger(vs[0]) < len(b.params)-1 {
+		return nil, preprocessingError(ctx, imms, "too few operands")
+	}
+	return vs, nil
+}
+
+// toGo converts a value to a Go value of the given type.
+func toGo(v Value, t reflect.Type) (reflect.Value, error) {
+	switch t.Kind() {
+	case reflect.Bool:
+		return reflect.ValueOf(ToBoolean(v)).Convert(t), nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		i, ok := toGoInt(v)
+		val := reflect.New(t).Elem()
+		if !ok || val.OverflowInt(i) {
+			return reflect.Value{}, errors.Errorf("%s overflows %s", ToString(v), t)
+		}
+		val.SetInt(i)
+		return val, nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		u, ok := toGoUint(v)
+		val := reflect.New(t).Elem()
+		if !ok || val.OverflowUint(u) {
+			return reflect.Value{}, errors.Errorf("%s overflows %s", ToString(v), t)
+		}
+		val.SetUint(u)
+		return val, nil
+	case reflect.Float32, reflect.Float64:
+		f := ToNumber(v)
+		val := reflect.New(t).Elem()
+		if !math.IsInf(f, 0) && val.OverflowFloat(f) {
+			return reflect.Value{}, errors.Errorf("%s overflows %s", ToString(v), t)
+		}
+		val.SetFloat(f)
+		return val, nil
+	case reflect.String:
+		return reflect.ValueOf(ToString(v)).Convert(t), nil
+	case reflect.Slice:
+		a, ok := arrayOf(v)
+		if !ok {
+			break
+		}
+
+		s := reflect.MakeSlice(t, len(a), len(a))
+		for i, e := range a {
+			elem, err := toGo(e, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			s.Index(i).Set(elem)
+		}
+		return s, nil
+	case reflect.Map:
+		o, ok := objectOf(v)
+		if !ok || t.Key().Kind() != reflect.String {
+			break
+		}
+
+		m := reflect.MakeMapWithSize(t, len(o))
+		for k, e := range o {
+			elem, err := toGo(e, t.Elem())
+			if err != nil {
+				return reflect.Value{}, err
+			}
+			m.SetMapIndex(reflect.ValueOf(k).Convert(t.Key()), elem)
+		}
+		return m, nil
+	}
+
+	if v == nil {
+		switch t.Kind() {
+		case reflect.Interface, reflect.Slice, reflect.Map, reflect.Ptr:
+			return reflect.Zero(t), nil
+		}
+	} else if val := reflect.ValueOf(v); val.Type().AssignableTo(t) {
+		return val, nil
+	}
+
+	return reflect.Value{}, errors.Errorf("cannot convert %s to %s", TypeOf(v), t)
+}
+
+// toGoInt converts a value to an integer discarding the fraction,
+// and reports whether the value is in the range of int64.
+func toGoInt(v Value) (int64, bool) {
+	switch v := normalize(v).(type) {
+	case int64:
+		return v, true
+	case uint64:
+		return int64(v), v <= math.MaxInt64
+	}
+
+	f := math.Trunc(ToNumber(v))
+	return int64(f), f >= math.MinInt64 && f < math.MaxInt64
+}
+
+// toGoUint converts a value to an unsigned integer discarding the fraction,
+// and reports whether the value is in the range of uint64.
+func toGoUint(v Value) (uint64, bool) {
+	switch v := normalize(v).(type) {
+	case int64:
+		return uint64(v), v >= 0
+	case uint64:
+		return v, true
+	}
+
+	f := math.Trunc(ToNumber(v))
+	return uint64(f), f >= 0 && f < math.MaxUint64
+}
+
+// fromGo converts a Go value to a value.
+func fromGo(val reflect.Value) Value {
+	switch val.Kind() {
+	case reflect.Bool:
+		return BooleanValue(val.Bool())
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return intValue(val.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return uintValue(val.Uint())
+	case reflect.Float32, reflect.Float64:
+		return NumberValue(val.Float())
+	case reflect.String:
+		return StringValue(val.String())
+	case reflect.Slice:
+		if val.IsNil() {
+			return NullValue()
+		}
+
+		a := make([]Value, val.Len())
+		for i := range a {
+			a[i] = fromGo(val.Index(i))
+		}
+		return ArrayValue(a)
+	case reflect.Map:
+		if val.IsNil() {
+			return NullValue()
+		}
+
+		o := make(map[string]Value, val.Len())
+		for _, k := range val.MapKeys() {
+			o[ToString(k.Interface())] = fromGo(val.MapIndex(k))
+		}
+		return ObjectValue(o)
+	case reflect.Interface:
+		if val.IsNil() {
+			return NullValue()
+		}
+		return fromGo(val.Elem())
+	case reflect.Invalid:
+		return NullValue()
+	default:
+		return val.Interface()
+	}
+}
@@ -0,0 +1,145 @@
+package jsm
+
+import (
+	"context"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBind(t *testing.T) {
+	assert := assert.New(t)
+
+	m := NewMachine()
+	assert.NoError(m.Bind("strlen", func(s string) int { return len(s) }))
+	assert.NoError(m.Bind("repeat", strings.Repeat))
+	assert.NoError(m.Bind("sum", func(base float64, ns ...int) float64 {
+		for _, n := range ns {
+			base += float64(n)
+		}
+		return base
+	}))
+	assert.NoError(m.Bind("names", func(o map[string]Value) []string {
+		var ks []string
+		for k := range o {
+			ks = append(ks, k)
+		}
+		return ks
+	}))
+	assert.NoError(m.Bind("root", func(f float64) (float64, error) {
+		if f < 0 {
+			return 0, errors.New("negative number")
+		}
+		return math.Sqrt(f), nil
+	}))
+	assert.NoError(m.Bind("depth", func(ctx context.Context) int {
+		return len(*getCallStack(ctx))
+	}))
+
+	p := []Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{StringValue("ab"), IntegerValue(2)}},
+		{Mnemonic: "repeat"},
+		{Mnemonic: "strlen"},
+		{Mnemonic: MnemonicPush, Immediates: []Value{NumberValue(0.5), IntegerValue(1), StringValue("2")}},
+		{Mnemonic: "sum", Immediates: []Value{IntegerValue(3)}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: "sum"},
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: "names"},
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: "root"},
+		{Mnemonic: "depth"},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(6)}},
+	}
+
+	res, err := m.Run(p, []Value{ObjectValue(map[string]Value{"a": IntegerValue(1)}), IntegerValue(9)})
+	assert.NoError(err)
+	assert.Equal([]Value{
+		IntegerValue(4),
+		NumberValue(3.5),
+		NumberValue(1.0),
+		ArrayValue([]Value{StringValue("a")}),
+		NumberValue(3.0),
+		IntegerValue(1),
+	}, res)
+
+	_, err = m.Run(p, []Value{ObjectValue(map[string]Value{}), IntegerValue(-3)})
+	assert.EqualError(err, "root at 10: negative number")
+
+	_, err = m.Run(p, []Value{StringValue("a"), IntegerValue(3)})
+	assert.EqualError(err, "names at 8: cannot convert string to map[string]jsm.Value")
+}
+
+func TestToGoOverflow(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		v   Value
+		t   interface{}
+		res interface{}
+		err string
+	}{
+		{IntegerValue(-1), uint(0), nil, "-1 overflows uint"},
+		{IntegerValue(255), uint8(0), uint8(255), ""},
+		{IntegerValue(256), uint8(0), nil, "256 overflows uint8"},
+		{NumberValue(-128.5), int8(0), int8(-128), ""},
+		{IntegerValue(-129), int8(0), nil, "-129 overflows int8"},
+		{IntegerValue(40000), int16(0), nil, "40000 overflows int16"},
+		{uint64(math.MaxUint64), uint64(0), uint64(math.MaxUint64), ""},
+		{uint64(math.MaxUint64), int64(0), nil, "18446744073709551615 overflows int64"},
+		{int64(math.MinInt64), int64(0), int64(math.MinInt64), ""},
+		{NumberValue(1e20), int64(0), nil, "1e+20 overflows int64"},
+		{NumberValue(math.NaN()), int(0), nil, "NaN overflows int"},
+		{StringValue("12"), uint16(0), uint16(12), ""},
+		{NumberValue(1e300), float32(0), nil, "1e+300 overflows float32"},
+		{NumberValue(math.Inf(1)), float32(0), float32(math.Inf(1)), ""},
+	}
+
+	for _, test := range tests {
+		val, err := toGo(test.v, reflect.TypeOf(test.t))
+		if test.err != "" {
+			assert.EqualError(err, test.err, test.v)
+			continue
+		}
+		assert.NoError(err, test.v)
+		assert.Equal(test.res, val.Interface(), test.v)
+	}
+
+	m := NewMachine()
+	assert.NoError(m.Bind("byte", func(b byte) byte { return b }))
+	_, err := m.Run([]Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(-1)}},
+		{Mnemonic: "byte"},
+	}, nil)
+	assert.EqualError(err, "byte at 1: -1 overflows uint8")
+}
+
+func TestBindErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	m := NewMachine()
+	assert.EqualError(m.Bind("f", 1), "not a function")
+	assert.EqualError(m.Bind("f", func() (int, int) { return 0, 0 }), "second result is not an error")
+	assert.EqualError(m.Bind("f", func() (int, int, error) { return 0, 0, nil }), "too many results")
+	assert.EqualError(m.Bind(MnemonicAdd, func() {}), "add already defined")
+
+	assert.NoError(m.Bind("f", func(int, ...int) {}))
+	_, err := m.Run([]Instruction{{Mnemonic: "f", Immediates: []Value{IntegerValue(0)}}}, nil)
+	assert.EqualError(err, `too few operands: {"mnemonic":"f","immediates":[0]}`)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: "f", Immediates: []Value{IntegerValue(2)}},
+	}
+	_, err = m.Run(p, nil)
+	assert.EqualError(err, "stack underflow at 1: f needs 2 operands but has 1")
+
+	m = NewMachine(WithoutVerification())
+	assert.NoError(m.Bind("f", func(int, ...int) {}))
+	_, err = m.Run(p, nil)
+	assert.EqualError(err, "f at 1: too few operands")
+}
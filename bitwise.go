@@ -0,0 +1,166 @@
+package jsm
+
+import (
+	"math"
+	"math/big"
+)
+
+// The bitwise instructions convert their operands with ToInt32 or ToUint32,
+// and use the lower 5 bits of the right operand as a shift count like JavaScript.
+// In the 64-bit integer mode enabled by WithInt64, they operate on 64-bit integers
+// and use the lower 6 bits of the right operand as a shift count instead.
+
+func band(vs []Value) (Value, error) {
+	return IntegerValue(int(ToInt32(vs[0]) & ToInt32(vs[1]))), nil
+}
+
+func bor(vs []Value) (Value, error) {
+	return IntegerValue(int(ToInt32(vs[0]) | ToInt32(vs[1]))), nil
+}
+
+func bxor(vs []Value) (Value, error) {
+	return IntegerValue(int(ToInt32(vs[0]) ^ ToInt32(vs[1]))), nil
+}
+
+func bnot(vs []Value) (Value, error) {
+	return IntegerValue(int(^ToInt32(vs[0]))), nil
+}
+
+func shl(vs []Value) (Value, error) {
+	return IntegerValue(int(ToInt32(vs[0]) << (ToUint32(vs[1]) & 31))), nil
+}
+
+func shr(vs []Value) (Value, error) {
+	return IntegerValue(int(ToInt32(vs[0]) >> (ToUint32(vs[1]) & 31))), nil
+}
+
+func ushr(vs []Value) (Value, error) {
+	return uintValue(uint64(ToUint32(vs[0]) >> (ToUint32(vs[1]) & 31))), nil
+}
+
+func band64(vs []Value) (Value, error) {
+	i1, u1 := toBits64(vs[0])
+	i2, u2 := toBits64(vs[1])
+	return bitsValue(i1&i2, u1 || u2), nil
+}
+
+func bor64(vs []Value) (Value, error) {
+	i1, u1 := toBits64(vs[0])
+	i2, u2 := toBits64(vs[1])
+	return bitsValue(i1|i2, u1 || u2), nil
+}
+
+func bxor64(vs []Value) (Value, error) {
+	i1, u1 := toBits64(vs[0])
+	i2, u2 := toBits64(vs[1])
+	return bitsValue(i1^i2, u1 || u2), nil
+}
+
+func bnot64(vs []Value) (Value, error) {
+	i, u := toBits64(vs[0])
+	return bitsValue(^i, u), nil
+}
+
+func shl64(vs []Value) (Value, error) {
+	i, u := toBits64(vs[0])
+	return bitsValue(i<<(uint64(toInt64(vs[1]))&63), u), nil
+}
+
+func shr64(vs []Value) (Value, error) {
+	i, u := toBits64(vs[0])
+	n := uint64(toInt64(vs[1])) & 63
+	if u {
+		return uintValue(uint64(i) >> n), nil
+	}
+	return intValue(i >> n), nil
+}
+
+func ushr64(vs []Value) (Value, error) {
+	return uintValue(uint64(toInt64(vs[0])) >> (uint64(toInt64(vs[1])) & 63)), nil
+}
+
+// toBits64 converts the given value to 64 bits,
+// and reports whether it is an unsigned integer not representable by int64.
+func toBits64(v Value) (int64, bool) {
+	_, u := normalize(v).(uint64)
+	i := toInt64(v)
+	return i, u && i < 0
+}
+
+// bitsValue returns an integer value representing i as a signed or unsigned integer.
+func bitsValue(i int64, unsigned bool) Value {
+	if unsigned {
+		return uintValue(uint64(i))
+	}
+	return intValue(i)
+}
+
+// In the 64-bit integer mode, add, sub and mul are also exact if both operands are integers.
+// Their results are floats if they are safe integers as in the normal mode.
+
+func add64(vs []Value) (Value, error) {
+	return exactOp(vs, add, (*big.Int).Add)
+}
+
+func sub64(vs []Value) (Value, error) {
+	return exactOp(vs, sub, (*big.Int).Sub)
+}
+
+func mul64(vs []Value) (Value, error) {
+	return exactOp(vs, mul, (*big.Int).Mul)
+}
+
+func exactOp(vs []Value, op func([]Value) (Value, error), exact func(z, x, y *big.Int) *big.Int) (Value, error) {
+	x, ok1 := toBigInt(vs[0])
+	y, ok2 := toBigInt(vs[1])
+	if !ok1 || !ok2 {
+		return op(vs)
+	}
+
+	z := exact(new(big.Int), x, y)
+	switch {
+	case z.IsInt64():
+		i := z.Int64()
+		if i >= -maxSafeInteger && i <= maxSafeInteger {
+			return NumberValue(float64(i)), nil
+		}
+		return intValue(i), nil
+	case z.IsUint64():
+		return uintValue(z.Uint64()), nil
+	default:
+		f, _ := new(big.Float).SetInt(z).Float64()
+		return NumberValue(f), nil
+	}
+}
+
+// toBigInt converts an integer value to a big.Int and reports whether it is an integer.
+// Floats beyond 2^53 are not regarded as integers because they may have been rounded.
+func toBigInt(v Value) (*big.Int, bool) {
+	switch n := normalize(v).(type) {
+	case int64:
+		return big.NewInt(n), true
+	case uint64:
+		return new(big.Int).SetUint64(n), true
+	case float64:
+		if n == math.Trunc(n) && n >= -maxSafeInteger && n <= maxSafeInteger {
+			return big.NewInt(int64(n)), true
+		}
+	}
+	return nil, false
+}
+
+// intValue returns an integer value representing i, which is an int if possible.
+func intValue(i int64) Value {
+	if i < minInt || i > maxInt {
+		return i
+	}
+	return IntegerValue(int(i))
+}
+
+// uintValue returns an integer value representing u, which is an int if possible.
+func uintValue(u uint64) Value {
+	if u > maxInt {
+		return u
+	}
+	return IntegerValue(int(u))
+}
@@ -0,0 +1,132 @@
+package jsm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBitwiseOperations(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		op  func([]Value) (Value, error)
+		vs  []Value
+		res Value
+	}{
+		{band, []Value{IntegerValue(12), IntegerValue(10)}, IntegerValue(8)},
+		{bor, []Value{IntegerValue(12), IntegerValue(10)}, IntegerValue(14)},
+		{bxor, []Value{IntegerValue(12), IntegerValue(10)}, IntegerValue(6)},
+		{bnot, []Value{IntegerValue(0)}, IntegerValue(-1)},
+		{bnot, []Value{NumberValue(1.9)}, IntegerValue(-2)},
+		{bor, []Value{NumberValue(4294967296.0 + 5), IntegerValue(0)}, IntegerValue(5)},
+		{bor, []Value{NumberValue(2147483648.0), IntegerValue(0)}, IntegerValue(-2147483648)},
+		{bor, []Value{NumberValue(math.NaN()), StringValue("3")}, IntegerValue(3)},
+		{shl, []Value{IntegerValue(1), IntegerValue(31)}, IntegerValue(-2147483648)},
+		{shl, []Value{IntegerValue(1), IntegerValue(33)}, IntegerValue(2)},
+		{shr, []Value{IntegerValue(-8), IntegerValue(1)}, IntegerValue(-4)},
+		{ushr, []Value{IntegerValue(-1), IntegerValue(0)}, IntegerValue(4294967295)},
+		{ushr, []Value{IntegerValue(-8), IntegerValue(28)}, IntegerValue(15)},
+		{band64, []Value{uint64(1<<63 + 1), IntegerValue(-1)}, uint64(1<<63 + 1)},
+		{bor64, []Value{uint64(1<<63 + 1), IntegerValue(0)}, uint64(1<<63 + 1)},
+		{bor64, []Value{int64(-1<<63 + 1), IntegerValue(0)}, int64(-1<<63 + 1)},
+		{bnot64, []Value{uint64(1 << 63)}, uint64(1<<63 - 1)},
+		{shr64, []Value{uint64(1 << 63), IntegerValue(62)}, IntegerValue(2)},
+		{bor64, []Value{IntegerValue(1 << 40), IntegerValue(1)}, IntegerValue(1<<40 + 1)},
+		{bxor64, []Value{IntegerValue(-1), IntegerValue(1)}, IntegerValue(-2)},
+		{bnot64, []Value{IntegerValue(0)}, IntegerValue(-1)},
+		{shl64, []Value{IntegerValue(1), IntegerValue(40)}, IntegerValue(1 << 40)},
+		{shl64, []Value{IntegerValue(1), IntegerValue(65)}, IntegerValue(2)},
+		{shr64, []Value{IntegerValue(-1 << 40), IntegerValue(38)}, IntegerValue(-4)},
+		{ushr64, []Value{IntegerValue(-1), IntegerValue(0)}, uint64(math.MaxUint64)},
+		{ushr64, []Value{IntegerValue(-1), IntegerValue(60)}, IntegerValue(15)},
+	}
+
+	for _, test := range tests {
+		res, err := test.op(test.vs)
+		assert.NoError(err)
+		assert.True(Equal(test.res, res), "%v: %v", test.vs, res)
+	}
+}
+
+func TestBitwiseInstructions(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicShiftLeft, Immediates: []Value{IntegerValue(32)}},
+		{Mnemonic: MnemonicBitwiseOr, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	}
+
+	m := NewMachine()
+	res, err := m.Run(p, []Value{IntegerValue(2)})
+	assert.NoError(err)
+	assert.Equal([]Value{IntegerValue(3)}, res)
+
+	m = NewMachine(WithInt64())
+	res, err = m.Run(p, []Value{IntegerValue(2)})
+	assert.NoError(err)
+	assert.Equal([]Value{IntegerValue(1<<33 + 1)}, res)
+
+	p[1].Immediates = []Value{IntegerValue(62)}
+	res, err = m.Run(p, []Value{IntegerValue(2)})
+	assert.NoError(err)
+	assert.True(Equal([]Value{int64(-1<<63 + 1)}, res))
+
+	res, err = NewMachine().Run(p, []Value{IntegerValue(2)})
+	assert.NoError(err)
+	assert.Equal([]Value{IntegerValue(-2147483647)}, res)
+
+	p = []Instruction{
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicBitwiseOr},
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicEqual},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	}
+	res, err = m.Run(p, []Value{uint64(1<<63 + 1)})
+	assert.NoError(err)
+	assert.Equal([]Value{BooleanValue(true)}, res)
+}
+
+func TestExactArithmetic(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		op  func([]Value) (Value, error)
+		vs  []Value
+		res Value
+	}{
+		{add64, []Value{IntegerValue(1), NumberValue(0.5)}, NumberValue(1.5)},
+		{add64, []Value{IntegerValue(1 << 53), IntegerValue(1)}, IntegerValue(1<<53 + 1)},
+		{add64, []Value{uint64(1<<63 + 1), NumberValue(1.0)}, uint64(1<<63 + 2)},
+		{add64, []Value{uint64(math.MaxUint64), IntegerValue(1)}, NumberValue(1 << 64)},
+		{add64, []Value{uint64(1 << 63), NumberValue(0.5)}, NumberValue(1<<63 + 0.5)},
+		{sub64, []Value{uint64(1<<63 + 1), uint64(1 << 63)}, NumberValue(1.0)},
+		{sub64, []Value{IntegerValue(0), uint64(1<<63 + 1)}, NumberValue(-(1<<63 + 1))},
+		{sub64, []Value{int64(-1 << 62), uint64(1 << 62)}, int64(-1 << 63)},
+		{mul64, []Value{IntegerValue(1<<32 + 1), IntegerValue(1<<31 + 1)}, uint64(1<<63 + 1<<32 + 1<<31 + 1)},
+	}
+
+	for _, test := range tests {
+		res, err := test.op(test.vs)
+		assert.NoError(err)
+		assert.True(Equal(test.res, res), "%v: %v", test.vs, res)
+	}
+
+	p := []Instruction{
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicAdd},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	}
+
+	for _, opt := range []Option{WithOptimization(), WithCompilation()} {
+		res, err := NewMachine(WithInt64(), opt).Run(p, []Value{uint64(1<<63 + 1)})
+		assert.NoError(err)
+		assert.Equal([]Value{uint64(1<<63 + 2)}, res)
+	}
+}
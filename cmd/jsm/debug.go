@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/plenluno/jsm"
+	"github.com/plenluno/jsm/asm"
+)
+
+func debug(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("debug", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "", "program format: json or asm (default: by file extension)")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: jsm debug [flags] program [args...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	p, err := loadProgram(fs.Arg(0), *format)
+	if err != nil {
+		fmt.Fprintf(stderr, "jsm: %v\n", err)
+		return exitError
+	}
+
+	if fs.NArg() == 2 && fs.Arg(1) == "-" {
+		fmt.Fprintln(stderr, "jsm: cannot read arguments from the standard input while debugging")
+		return exitUsage
+	}
+
+	vs, err := parseArguments(fs.Args()[1:], nil)
+	if err != nil {
+		fmt.Fprintf(stderr, "jsm: %v\n", err)
+		return exitError
+	}
+
+	d := jsm.NewDebugger(jsm.NewMachine())
+	if err := d.Load(p, vs); err != nil {
+		fmt.Fprintf(stderr, "jsm: %v\n", err)
+		return exitError
+	}
+
+	r := &repl{debugger: d, out: stdout}
+	r.run(stdin)
+	return exitOK
+}
+
+type repl struct {
+	debugger jsm.Debugger
+	out      io.Writer
+}
+
+const replHelp = `Commands:
+	s, step              execute the current instruction
+	n, next              execute the current instruction, stepping over calls
+	o, out               run until the current frame returns
+	c, continue          run until a breakpoint or a watchpoint is hit
+	b, break [loc]       set a breakpoint at an index or a label, or list breakpoints
+	d, delete loc        delete the breakpoint at an index or a label
+	w, watch key         watch a key of the global heap
+	unwatch key          stop watching a key of the global heap
+	p, print             print the current instruction, operands and locals
+	bt, backtrace        print the call stack
+	h, help              print this help
+	q, quit              quit the debugger
+`
+
+func (r *repl) run(in io.Reader) {
+	r.printState()
+
+	s := bufio.NewScanner(in)
+	for {
+		fmt.Fprint(r.out, "(jsm) ")
+		if !s.Scan() {
+			fmt.Fprintln(r.out)
+			return
+		}
+
+		fields := strings.Fields(s.Text())
+		if len(fields) == 0 {
+			continue
+		}
+
+		if !r.exec(fields[0], fields[1:]) {
+			return
+		}
+	}
+}
+
+// exec executes a command and reports whether the REPL should continue.
+func (r *repl) exec(cmd string, args []string) bool {
+	d := r.debugger
+	switch cmd {
+	case "s", "step":
+		r.report(d.Step())
+	case "n", "next":
+		r.report(d.StepOver())
+	case "o", "out":
+		r.report(d.StepOut())
+	case "c", "continue":
+		r.report(d.Continue())
+	case "b", "break":
+		if len(args) == 0 {
+			fmt.Fprintf(r.out, "breakpoints: %v\n", d.Breakpoints())
+		} else if err := d.SetBreakpoint(location(args[0])); err != nil {
+			fmt.Fprintf(r.out, "error: %v\n", err)
+		}
+	case "d", "delete":
+		if len(args) == 0 {
+			fmt.Fprintln(r.out, "error: no location")
+		} else if err := d.ClearBreakpoint(location(args[0])); err != nil {
+			fmt.Fprintf(r.out, "error: %v\n", err)
+		}
+	case "w", "watch":
+		if len(args) == 0 {
+			fmt.Fprintln(r.out, "error: no key")
+		} else {
+			d.Watch(args[0])
+		}
+	case "unwatch":
+		if len(args) == 0 {
+			fmt.Fprintln(r.out, "error: no key")
+		} else {
+			d.Unwatch(args[0])
+		}
+	case "p", "print":
+		r.printState()
+	case "bt", "backtrace":
+		r.printCallStack()
+	case "h", "help":
+		fmt.Fprint(r.out, replHelp)
+	case "q", "quit":
+		return false
+	default:
+		fmt.Fprintf(r.out, "error: unknown command %q\n", cmd)
+	}
+	return true
+}
+
+func (r *repl) report(stop jsm.Stop, err error) {
+	if err != nil {
+		fmt.Fprintf(r.out, "error: %v\n", err)
+		return
+	}
+
+	switch stop.Reason {
+	case jsm.StopBreakpoint:
+		fmt.Fprintf(r.out, "breakpoint at %d\n", stop.PC)
+	case jsm.StopWatchpoint:
+		fmt.Fprintf(r.out, "%s: %s -> %s\n", stop.Key, toJSON(stop.Old), toJSON(stop.New))
+	}
+	r.printState()
+}
+
+func (r *repl) printState() {
+	d := r.debugger
+	inst, ok := d.Instruction()
+	if !ok {
+		fmt.Fprintf(r.out, "terminated: %s\n", toJSON(d.Result()))
+		return
+	}
+
+	fmt.Fprintf(r.out, "=> %d: %s\n", d.PC(), formatInstruction(inst))
+
+	cs := d.CallStack()
+	if len(cs) > 0 {
+		f := cs[len(cs)-1]
+		fmt.Fprintf(r.out, "   operands: %s\n", toJSON(f.Operands))
+		fmt.Fprintf(r.out, "   locals: %s\n", toJSON(f.Locals))
+	}
+}
+
+func (r *repl) printCallStack() {
+	cs := r.debugger.CallStack()
+	for i := len(cs) - 1; i >= 0; i-- {
+		f := cs[i]
+		fmt.Fprintf(r.out, "#%d arguments: %s, return to: %d\n", i, toJSON(f.Arguments), f.ReturnTo)
+	}
+}
+
+func location(s string) jsm.Value {
+	if idx, err := strconv.Atoi(s); err == nil {
+		return jsm.IntegerValue(idx)
+	}
+	return jsm.StringValue(s)
+}
+
+func formatInstruction(inst jsm.Instruction) string {
+	label := inst.Label
+	inst.Label = ""
+
+	s, err := asm.DisassembleString([]jsm.Instruction{inst})
+	if err != nil {
+		s = string(inst.Mnemonic)
+	}
+	s = strings.TrimSpace(s)
+
+	if label != "" {
+		return label + ": " + s
+	}
+	return s
+}
+
+func toJSON(v interface{}) string {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return string(data)
+}
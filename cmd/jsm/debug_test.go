@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDebug(t *testing.T) {
+	assert := assert.New(t)
+
+	path := writeProgram(t, "sum.jsm", `	push 0
+	stl i, 1
+loop:	ldl i
+	lda 0
+	le
+	jf exit
+	ldl i
+	add ; sum += i
+	inc count
+	incl i
+	jmp loop
+exit:	ret 1
+`)
+
+	in := strings.Join([]string{
+		"s", "", "b loop", "b 99", "b", "c", "w count", "c", "p", "bt",
+		"unwatch count", "d loop", "n", "o", "s", "foo", "q", "",
+	}, "\n")
+
+	var stdout, stderr bytes.Buffer
+	code := debug([]string{path, "2"}, strings.NewReader(in), &stdout, &stderr)
+	assert.Equal(exitOK, code, stderr.String())
+	assert.Equal(`=> 0: push 0
+   operands: []
+   locals: {}
+(jsm) => 1: stl i, 1
+   operands: [0]
+   locals: {}
+(jsm) (jsm) (jsm) error: invalid address: 99
+(jsm) breakpoints: [2]
+(jsm) breakpoint at 2
+=> 2: loop: ldl i
+   operands: [0]
+   locals: {"i":1}
+(jsm) (jsm) count: null -> 1
+=> 9: incl i
+   operands: [1]
+   locals: {"i":1}
+(jsm) => 9: incl i
+   operands: [1]
+   locals: {"i":1}
+(jsm) #0 arguments: [2], return to: 12
+(jsm) (jsm) (jsm) => 10: jmp loop
+   operands: [1]
+   locals: {"i":2}
+(jsm) terminated: [3]
+(jsm) terminated: [3]
+(jsm) error: unknown command "foo"
+(jsm) `, stdout.String())
+}
+
+func TestDebugUsage(t *testing.T) {
+	assert := assert.New(t)
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(exitUsage, debug(nil, nil, &stdout, &stderr))
+	assert.Contains(stderr.String(), "Usage: jsm debug")
+
+	stderr.Reset()
+	path := writeProgram(t, "nop.jsm", "nop\n")
+	assert.Equal(exitUsage, debug([]string{path, "-"}, nil, &stdout, &stderr))
+}
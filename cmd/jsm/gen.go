@@ -0,0 +1,106 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	"github.com/plenluno/jsm"
+	"github.com/plenluno/jsm/gen"
+)
+
+func generate(args []string, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("gen", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "", "program format: json or asm (default: by file extension)")
+	pkg := fs.String("package", "main", "package name of the generated code")
+	fn := fs.String("func", "", "function name of the generated code (default: by file name)")
+	out := fs.String("o", "", "output file (default: the standard output)")
+	test := fs.String("test", "", "output file of a test comparing the function with Machine.Run")
+	argss := fs.String("args", "[[]]", "JSON array of the argument lists used by the test")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: jsm gen [flags] program")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	p, err := loadProgram(fs.Arg(0), *format)
+	if err != nil {
+		fmt.Fprintf(stderr, "jsm: %v\n", err)
+		return exitError
+	}
+
+	cfg := gen.Config{Package: *pkg, Func: *fn}
+	if cfg.Func == "" {
+		cfg.Func = funcName(fs.Arg(0))
+	}
+
+	var src bytes.Buffer
+	if err := gen.Generate(&src, p, cfg); err != nil {
+		fmt.Fprintf(stderr, "jsm: %v\n", err)
+		return exitError
+	}
+
+	if *test != "" {
+		var vs [][]jsm.Value
+		if err := json.Unmarshal([]byte(*argss), &vs); err != nil {
+			fmt.Fprintf(stderr, "jsm: invalid arguments: %v\n", err)
+			return exitError
+		}
+
+		var buf bytes.Buffer
+		if err := gen.GenerateTest(&buf, p, cfg, vs); err != nil {
+			fmt.Fprintf(stderr, "jsm: %v\n", err)
+			return exitError
+		}
+
+		if err := ioutil.WriteFile(*test, buf.Bytes(), 0644); err != nil {
+			fmt.Fprintf(stderr, "jsm: %v\n", err)
+			return exitError
+		}
+	}
+
+	if *out == "" {
+		stdout.Write(src.Bytes())
+		return exitOK
+	}
+
+	if err := ioutil.WriteFile(*out, src.Bytes(), 0644); err != nil {
+		fmt.Fprintf(stderr, "jsm: %v\n", err)
+		return exitError
+	}
+	return exitOK
+}
+
+// funcName converts the name of a program file into an exported function name,
+// e.g. "sum_of_series.json" into "SumOfSeries".
+func funcName(path string) string {
+	base := filepath.Base(path)
+	base = strings.TrimSuffix(base, filepath.Ext(base))
+
+	var b strings.Builder
+	for _, w := range strings.FieldsFunc(base, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	}) {
+		rs := []rune(w)
+		b.WriteRune(unicode.ToUpper(rs[0]))
+		b.WriteString(string(rs[1:]))
+	}
+	return b.String()
+}
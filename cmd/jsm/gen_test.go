@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerate(t *testing.T) {
+	assert := assert.New(t)
+
+	var stdout, stderr bytes.Buffer
+	code := generate([]string{"-package", "fib", "../../examples/fibonacci.json"}, &stdout, &stderr)
+	assert.Equal(exitOK, code, stderr.String())
+	assert.Contains(stdout.String(), "package fib\n")
+	assert.Contains(stdout.String(), "func Fibonacci(args []jsm.Value) (jsm.Value, error) {")
+
+	path := writeProgram(t, "concat.jsm", "lda 0\nlda 1\nconcat\nret 1\n")
+	dir := filepath.Dir(path)
+	out := filepath.Join(dir, "concat.go")
+	test := filepath.Join(dir, "concat_test.go")
+
+	stdout.Reset()
+	code = generate([]string{"-func", "join", "-o", out, "-test", test, "-args", `[["a", "b"]]`, path}, &stdout, &stderr)
+	assert.Equal(exitOK, code, stderr.String())
+	assert.Empty(stdout.String())
+
+	src, err := ioutil.ReadFile(out)
+	assert.NoError(err)
+	assert.Contains(string(src), "func join(args []jsm.Value) (jsm.Value, error) {")
+
+	src, err = ioutil.ReadFile(test)
+	assert.NoError(err)
+	assert.Contains(string(src), "func TestJoin(t *testing.T) {")
+	assert.Contains(string(src), `[[\"a\",\"b\"]]`)
+}
+
+func TestGenerateFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(exitUsage, generate(nil, &stdout, &stderr))
+	assert.Contains(stderr.String(), "Usage: jsm gen")
+
+	path := writeProgram(t, "typeof.jsm", "lda 0\ntypeof\nret 1\n")
+	stderr.Reset()
+	assert.Equal(exitError, generate([]string{path}, &stdout, &stderr))
+	assert.Equal("jsm: unsupported instruction at 1: typeof\n", stderr.String())
+
+	stderr.Reset()
+	assert.Equal(exitError, generate([]string{"-test", "x_test.go", "-args", "[1]", "../../examples/fibonacci.json"}, &stdout, &stderr))
+	assert.Contains(stderr.String(), "jsm: invalid arguments")
+	assert.Empty(stdout.String())
+}
+
+func TestFuncName(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("Fibonacci", funcName("examples/fibonacci.json"))
+	assert.Equal("SumOfSeries", funcName("sum_of_series.json"))
+	assert.Equal("MyProgram2", funcName("/tmp/my-program 2.jsm"))
+}
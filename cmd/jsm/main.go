@@ -0,0 +1,74 @@
+// Command jsm runs JSM programs.
+//
+// Usage:
+//
+//	jsm run [flags] program [args...]
+//	jsm debug [flags] program [args...]
+//	jsm gen [flags] program
+//
+// The program is read from a JSON file, or from a text assembly file
+// if the file name does not end with ".json". Each argument is a JSON value.
+// If the only argument is "-", the arguments are read from the standard input
+// as a JSON array. The result is printed to the standard output as JSON.
+//
+// The debug command runs a program interactively. Type "help" at its prompt
+// for the list of commands.
+//
+// The gen command translates a program into a Go function that runs it
+// without the interpreter, and optionally into a test that compares
+// the function with Machine.Run.
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+)
+
+const (
+	exitOK    = 0
+	exitError = 1
+	exitUsage = 2
+)
+
+func main() {
+	os.Exit(dispatch(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+func dispatch(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		usage(stderr)
+		return exitUsage
+	}
+
+	switch args[0] {
+	case "run":
+		return run(args[1:], stdin, stdout, stderr)
+	case "debug":
+		return debug(args[1:], stdin, stdout, stderr)
+	case "gen":
+		return generate(args[1:], stdout, stderr)
+	case "help", "-h", "-help", "--help":
+		usage(stdout)
+		return exitOK
+	default:
+		fmt.Fprintf(stderr, "jsm: unknown command %q\n", args[0])
+		usage(stderr)
+		return exitUsage
+	}
+}
+
+func usage(w io.Writer) {
+	fmt.Fprint(w, `Usage:
+
+	jsm <command> [flags] [arguments]
+
+Commands:
+
+	run	run a program
+	debug	debug a program interactively
+	gen	generate Go code from a program
+
+Run "jsm <command> -h" for the flags of a command.
+`)
+}
@@ -0,0 +1,24 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDispatch(t *testing.T) {
+	assert := assert.New(t)
+
+	var stdout, stderr bytes.Buffer
+	assert.Equal(exitUsage, dispatch(nil, nil, &stdout, &stderr))
+	assert.Contains(stderr.String(), "Usage:")
+
+	stderr.Reset()
+	assert.Equal(exitUsage, dispatch([]string{"foo"}, nil, &stdout, &stderr))
+	assert.True(strings.HasPrefix(stderr.String(), "jsm: unknown command \"foo\"\n"))
+
+	assert.Equal(exitOK, dispatch([]string{"help"}, nil, &stdout, &stderr))
+	assert.Contains(stdout.String(), "Commands:")
+}
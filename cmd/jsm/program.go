@@ -0,0 +1,69 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+	"github.com/plenluno/jsm"
+	"github.com/plenluno/jsm/asm"
+)
+
+func loadProgram(path, format string) ([]jsm.Instruction, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "cannot open program")
+	}
+	defer f.Close()
+
+	if format == "" {
+		if strings.HasSuffix(path, ".json") {
+			format = "json"
+		} else {
+			format = "asm"
+		}
+	}
+
+	switch format {
+	case "json":
+		var p []jsm.Instruction
+		if err := json.NewDecoder(f).Decode(&p); err != nil {
+			return nil, errors.Wrap(err, "invalid program")
+		}
+		return p, nil
+	case "asm":
+		p, err := asm.Assemble(f)
+		if err != nil {
+			return nil, errors.Wrap(err, path)
+		}
+		return p, nil
+	default:
+		return nil, errors.Errorf("unknown format: %s", format)
+	}
+}
+
+func parseArguments(args []string, stdin io.Reader) ([]jsm.Value, error) {
+	if len(args) == 1 && args[0] == "-" {
+		data, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			return nil, errors.Wrap(err, "cannot read arguments")
+		}
+
+		var vs []jsm.Value
+		if err := json.Unmarshal(data, &vs); err != nil {
+			return nil, errors.Wrap(err, "invalid arguments")
+		}
+		return vs, nil
+	}
+
+	vs := make([]jsm.Value, len(args))
+	for i, arg := range args {
+		if err := json.Unmarshal([]byte(arg), &vs[i]); err != nil {
+			return nil, errors.Wrapf(err, "invalid argument %d", i)
+		}
+	}
+	return vs, nil
+}
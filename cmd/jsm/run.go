@@ -0,0 +1,81 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/plenluno/jsm"
+)
+
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	fs := flag.NewFlagSet("run", flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	format := fs.String("format", "", "program format: json or asm (default: by file extension)")
+	steps := fs.Int("steps", 0, "maximum number of instructions to execute (0 means unlimited)")
+	trace := fs.Bool("trace", false, "trace the execution to the standard error")
+	dump := fs.Bool("dump", false, "dump the machine state to the standard error on failure")
+	fs.Usage = func() {
+		fmt.Fprintln(stderr, "Usage: jsm run [flags] program [args...]")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return exitOK
+		}
+		return exitUsage
+	}
+
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return exitUsage
+	}
+
+	p, err := loadProgram(fs.Arg(0), *format)
+	if err != nil {
+		fmt.Fprintf(stderr, "jsm: %v\n", err)
+		return exitError
+	}
+
+	vs, err := parseArguments(fs.Args()[1:], stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "jsm: %v\n", err)
+		return exitError
+	}
+
+	var opts []jsm.Option
+	if *steps > 0 {
+		opts = append(opts, jsm.WithGasLimit(*steps))
+	}
+	if *trace {
+		opts = append(opts, jsm.WithTrace(stderr))
+	}
+
+	m := jsm.NewMachine(opts...)
+	res, err := m.Run(p, vs)
+	if err != nil {
+		fmt.Fprintf(stderr, "jsm: %v\n", err)
+		if *dump {
+			dumpMachine(m, stderr)
+		}
+		return exitError
+	}
+
+	data, err := json.Marshal(res)
+	if err != nil {
+		fmt.Fprintf(stderr, "jsm: cannot convert result to json: %v\n", err)
+		return exitError
+	}
+	fmt.Fprintln(stdout, string(data))
+	return exitOK
+}
+
+func dumpMachine(m jsm.Machine, w io.Writer) {
+	data, err := m.Dump()
+	if err != nil {
+		fmt.Fprintf(w, "jsm: %v\n", err)
+		return
+	}
+	fmt.Fprintln(w, string(data))
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeProgram(t *testing.T, name, src string) string {
+	dir, err := ioutil.TempDir("", "jsm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(dir) })
+
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestRunJSON(t *testing.T) {
+	assert := assert.New(t)
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"../../examples/fibonacci.json", "10"}, nil, &stdout, &stderr)
+	assert.Equal(exitOK, code, stderr.String())
+	assert.Equal("[55]\n", stdout.String())
+
+	stdout.Reset()
+	code = run([]string{"../../examples/sum_of_series.json", "-"}, strings.NewReader("[100]"), &stdout, &stderr)
+	assert.Equal(exitOK, code, stderr.String())
+	assert.Equal("[5050]\n", stdout.String())
+}
+
+func TestRunAsm(t *testing.T) {
+	assert := assert.New(t)
+
+	path := writeProgram(t, "concat.jsm", "lda 0\nlda 1\nret 2 ; both arguments\n")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{path, `"a"`, `{"b":[true,null]}`}, nil, &stdout, &stderr)
+	assert.Equal(exitOK, code, stderr.String())
+	assert.Equal("[\"a\",{\"b\":[true,null]}]\n", stdout.String())
+
+	stdout.Reset()
+	code = run([]string{"-format", "json", path}, nil, &stdout, &stderr)
+	assert.Equal(exitError, code)
+	assert.Contains(stderr.String(), "invalid program")
+}
+
+func TestRunTrace(t *testing.T) {
+	assert := assert.New(t)
+
+	path := writeProgram(t, "one.jsm", "push 1\nret 1\n")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-trace", path}, nil, &stdout, &stderr)
+	assert.Equal(exitOK, code, stderr.String())
+	assert.Equal("[1]\n", stdout.String())
+	assert.Equal("1\t0\tpush 1\t[]\n1\t1\tret 1\t[1]\n", stderr.String())
+}
+
+func TestRunFailure(t *testing.T) {
+	assert := assert.New(t)
+
+	path := writeProgram(t, "loop.jsm", "loop: jmp loop\n")
+
+	var stdout, stderr bytes.Buffer
+	code := run([]string{"-steps", "10", "-dump", path}, nil, &stdout, &stderr)
+	assert.Equal(exitError, code)
+	assert.Equal("", stdout.String())
+
+	lines := strings.Split(strings.TrimSpace(stderr.String()), "\n")
+	assert.Len(lines, 2)
+	assert.Equal("jsm: out of gas at 0: consumed 10 of 10", lines[0])
+	assert.True(strings.HasPrefix(lines[1], "{\"program\":"))
+
+	stderr.Reset()
+	code = run([]string{path, "[1"}, nil, &stdout, &stderr)
+	assert.Equal(exitError, code)
+	assert.Contains(stderr.String(), "invalid argument 0")
+
+	stderr.Reset()
+	code = run([]string{"no-such-file.json"}, nil, &stdout, &stderr)
+	assert.Equal(exitError, code)
+	assert.Contains(stderr.String(), "cannot open program")
+
+	stderr.Reset()
+	code = run(nil, nil, &stdout, &stderr)
+	assert.Equal(exitUsage, code)
+	assert.Contains(stderr.String(), "Usage: jsm run")
+}
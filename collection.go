@@ -0,0 +1,341 @@
+package jsm
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// The array and object instructions never modify their operands.
+// Instead, the instructions that update an array or object push a modified copy,
+// so that values shared by heaps, arguments and operand stacks are not affected.
+// Each of set, apush and del therefore takes time proportional to the size of the array or object,
+// and building an array of n elements with apush takes O(n^2) time.
+
+func arrayOf(v Value) ([]Value, bool) {
+	if a, ok := v.([]Value); ok {
+		return a, a != nil
+	}
+
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Slice || val.IsNil() {
+		return nil, false
+	}
+
+	a := make([]Value, val.Len())
+	for i := range a {
+		a[i] = val.Index(i).Interface()
+	}
+	return a, true
+}
+
+func objectOf(v Value) (map[string]Value, bool) {
+	if o, ok := v.(map[string]Value); ok {
+		return o, o != nil
+	}
+
+	val := reflect.ValueOf(v)
+	if val.Kind() != reflect.Map || val.IsNil() {
+		return nil, false
+	}
+
+	o := make(map[string]Value, val.Len())
+	for _, key := range val.MapKeys() {
+		o[ToString(key.Interface())] = val.MapIndex(key).Interface()
+	}
+	return o, true
+}
+
+func copyArray(a []Value) []Value {
+	return append(make([]Value, 0, len(a)+1), a...)
+}
+
+func copyObject(o map[string]Value) map[string]Value {
+	c := make(map[string]Value, len(o)+1)
+	for k, v := range o {
+		c[k] = v
+	}
+	return c
+}
+
+func sortedKeys(o map[string]Value) []string {
+	keys := make([]string, 0, len(o))
+	for k := range o {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func array(ctx context.Context, imms []Value) error {
+	n, err := getCount(imms, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	vs, err := doMultiPop(ctx, n)
+	if err != nil {
+		return err
+	}
+
+	if err := doPush(ctx, ArrayValue(copyArray(vs))); err != nil {
+		return err
+	}
+
+	GetProgramCounter(ctx).Increment()
+	return nil
+}
+
+func object(ctx context.Context, imms []Value) error {
+	n, err := getCount(imms, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	vs, err := doMultiPop(ctx, 2*n)
+	if err != nil {
+		return err
+	}
+
+	o := make(map[string]Value, n)
+	for i := 0; i < len(vs); i += 2 {
+		o[ToString(vs[i])] = vs[i+1]
+	}
+
+	if err := doPush(ctx, ObjectValue(o)); err != nil {
+		return err
+	}
+
+	GetProgramCounter(ctx).Increment()
+	return nil
+}
+
+// get returns the element at an index of an array or the value of a key of an object,
+// or null if there is no such element.
+func get(vs []Value) (Value, error) {
+	if a, ok := arrayOf(vs[0]); ok {
+		idx := ToInteger(vs[1])
+		if idx < 0 || idx >= len(a) {
+			return NullValue(), nil
+		}
+		return a[idx], nil
+	}
+
+	if o, ok := objectOf(vs[0]); ok {
+		return o[ToString(vs[1])], nil
+	}
+
+	return NullValue(), errors.New("not an array or object")
+}
+
+// set returns a copy of an array or object whose element at an index or key is replaced.
+// The index of an array may be its length, in which case the element is appended.
+func set(vs []Value) (Value, error) {
+	if a, ok := arrayOf(vs[0]); ok {
+		idx := ToInteger(vs[1])
+		if idx < 0 || idx > len(a) {
+			return NullValue(), errors.New("index out of range")
+		}
+
+		a = copyArray(a)
+		if idx == len(a) {
+			return ArrayValue(append(a, vs[2])), nil
+		}
+		a[idx] = vs[2]
+		return ArrayValue(a), nil
+	}
+
+	if o, ok := objectOf(vs[0]); ok {
+		o = copyObject(o)
+		o[ToString(vs[1])] = vs[2]
+		return ObjectValue(o), nil
+	}
+
+	return NullValue(), errors.New("not an array or object")
+}
+
+func apush(vs []Value) (Value, error) {
+	a, ok := arrayOf(vs[0])
+	if !ok {
+		return NullValue(), errors.New("not an array")
+	}
+
+	return ArrayValue(append(copyArray(a), vs[1])), nil
+}
+
+// apop pushes an array without its last element and the last element,
+// which is null if the array is empty.
+func apop(ctx context.Context, imms []Value) error {
+	v, err := doPop(ctx)
+	if err != nil {
+		return err
+	}
+
+	a, ok := arrayOf(v)
+	if !ok {
+		return errors.New("not an array")
+	}
+
+	last := NullValue()
+	if l := len(a); l > 0 {
+		last = a[l-1]
+		a = copyArray(a[:l-1])
+	}
+
+	if err := doMultiPush(ctx, []Value{ArrayValue(a), last}); err != nil {
+		return err
+	}
+
+	GetProgramCounter(ctx).Increment()
+	return nil
+}
+
+// length returns the number of elements of an array or object,
+// or the number of characters of a string.
+func length(vs []Value) (Value, error) {
+	if TypeOf(vs[0]) == TypeString {
+		return IntegerValue(utf8.RuneCountInString(ToString(vs[0]))), nil
+	}
+
+	if a, ok := arrayOf(vs[0]); ok {
+		return IntegerValue(len(a)), nil
+	}
+
+	if o, ok := objectOf(vs[0]); ok {
+		return IntegerValue(len(o)), nil
+	}
+
+	return NullValue(), errors.New("not an array, object or string")
+}
+
+// keys returns the indices of an array or the sorted keys of an object.
+func keys(vs []Value) (Value, error) {
+	if a, ok := arrayOf(vs[0]); ok {
+		ks := make([]Value, len(a))
+		for i := range a {
+			ks[i] = IntegerValue(i)
+		}
+		return ArrayValue(ks), nil
+	}
+
+	if o, ok := objectOf(vs[0]); ok {
+		ks := []Value{}
+		for _, k := range sortedKeys(o) {
+			ks = append(ks, StringValue(k))
+		}
+		return ArrayValue(ks), nil
+	}
+
+	return NullValue(), errors.New("not an array or object")
+}
+
+// values returns the elements of an array or the values of an object in the order of keys.
+func values(vs []Value) (Value, error) {
+	if a, ok := arrayOf(vs[0]); ok {
+		return ArrayValue(copyArray(a)), nil
+	}
+
+	if o, ok := objectOf(vs[0]); ok {
+		vals := []Value{}
+		for _, k := range sortedKeys(o) {
+			vals = append(vals, o[k])
+		}
+		return ArrayValue(vals), nil
+	}
+
+	return NullValue(), errors.New("not an array or object")
+}
+
+func has(vs []Value) (Value, error) {
+	if a, ok := arrayOf(vs[0]); ok {
+		idx := ToInteger(vs[1])
+		return BooleanValue(idx >= 0 && idx < len(a)), nil
+	}
+
+	if o, ok := objectOf(vs[0]); ok {
+		_, ok := o[ToString(vs[1])]
+		return BooleanValue(ok), nil
+	}
+
+	return NullValue(), errors.New("not an array or object")
+}
+
+// del returns a copy of an array without the element at an index,
+// or a copy of an object without a key.
+func del(vs []Value) (Value, error) {
+	if a, ok := arrayOf(vs[0]); ok {
+		idx := ToInteger(vs[1])
+		if idx < 0 || idx >= len(a) {
+			return ArrayValue(a), nil
+		}
+
+		c := make([]Value, 0, len(a)-1)
+		c = append(c, a[:idx]...)
+		return ArrayValue(append(c, a[idx+1:]...)), nil
+	}
+
+	if o, ok := objectOf(vs[0]); ok {
+		o = copyObject(o)
+		delete(o, ToString(vs[1]))
+		return ObjectValue(o), nil
+	}
+
+	return NullValue(), errors.New("not an array or object")
+}
+
+// spread pushes the given number of elements of an array,
+// filling the missing ones with nulls.
+func spread(ctx context.Context, imms []Value) error {
+	n, err := getCount(imms, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	v, err := doPop(ctx)
+	if err != nil {
+		return err
+	}
+
+	a, ok := arrayOf(v)
+	if !ok {
+		return errors.New("not an array")
+	}
+
+	if err := reserveOperands(ctx, n); err != nil {
+		return err
+	}
+
+	vs := make([]Value, n)
+	copy(vs, a)
+	if err := doMultiPush(ctx, vs); err != nil {
+		return err
+	}
+
+	GetProgramCounter(ctx).Increment()
+	return nil
+}
+
+func atMostTwoImmediates(ctx context.Context, imms []Value) ([]Value, error) {
+	if len(imms) > 2 {
+		return nil, preprocessingError(ctx, imms, "too many immediates")
+	}
+	return imms, nil
+}
+
+func oneCount(ctx context.Context, imms []Value) ([]Value, error) {
+	switch len(imms) {
+	case 0:
+		return nil, preprocessingError(ctx, imms, "no immediate")
+	case 1:
+		n := ToInteger(imms[0])
+		if n < 0 {
+			return nil, preprocessingError(ctx, imms, "invalid count")
+		}
+		return []Value{IntegerValue(n)}, nil
+	default:
+		return nil, preprocessingError(ctx, imms, "too many immediates")
+	}
+}
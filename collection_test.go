@@ -0,0 +1,156 @@
+package jsm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollectionOperations(t *testing.T) {
+	assert := assert.New(t)
+
+	a := ArrayValue([]Value{StringValue("a"), StringValue("b")})
+	o := ObjectValue(map[string]Value{"x": IntegerValue(1), "y": IntegerValue(2)})
+
+	tests := []struct {
+		op  func([]Value) (Value, error)
+		vs  []Value
+		res Value
+	}{
+		{get, []Value{a, IntegerValue(1)}, StringValue("b")},
+		{get, []Value{a, IntegerValue(2)}, NullValue()},
+		{get, []Value{o, StringValue("x")}, IntegerValue(1)},
+		{get, []Value{o, StringValue("z")}, NullValue()},
+		{get, []Value{[]string{"c"}, NumberValue(0.0)}, "c"},
+		{set, []Value{a, IntegerValue(0), StringValue("c")},
+			ArrayValue([]Value{StringValue("c"), StringValue("b")})},
+		{set, []Value{a, IntegerValue(2), StringValue("c")},
+			ArrayValue([]Value{StringValue("a"), StringValue("b"), StringValue("c")})},
+		{set, []Value{o, StringValue("z"), IntegerValue(3)},
+			ObjectValue(map[string]Value{"x": IntegerValue(1), "y": IntegerValue(2), "z": IntegerValue(3)})},
+		{apush, []Value{a, StringValue("c")},
+			ArrayValue([]Value{StringValue("a"), StringValue("b"), StringValue("c")})},
+		{length, []Value{a}, IntegerValue(2)},
+		{length, []Value{o}, IntegerValue(2)},
+		{length, []Value{StringValue("日本")}, IntegerValue(2)},
+		{keys, []Value{a}, ArrayValue([]Value{IntegerValue(0), IntegerValue(1)})},
+		{keys, []Value{o}, ArrayValue([]Value{StringValue("x"), StringValue("y")})},
+		{values, []Value{o}, ArrayValue([]Value{IntegerValue(1), IntegerValue(2)})},
+		{has, []Value{a, IntegerValue(1)}, BooleanValue(true)},
+		{has, []Value{a, IntegerValue(-1)}, BooleanValue(false)},
+		{has, []Value{o, StringValue("y")}, BooleanValue(true)},
+		{has, []Value{o, StringValue("z")}, BooleanValue(false)},
+		{del, []Value{a, IntegerValue(0)}, ArrayValue([]Value{StringValue("b")})},
+		{del, []Value{o, StringValue("x")}, ObjectValue(map[string]Value{"y": IntegerValue(2)})},
+	}
+
+	for _, test := range tests {
+		res, err := test.op(test.vs)
+		assert.NoError(err)
+		assert.Equal(test.res, res, "%v", test.vs)
+	}
+
+	assert.Equal(ArrayValue([]Value{StringValue("a"), StringValue("b")}), a)
+	assert.Equal(ObjectValue(map[string]Value{"x": IntegerValue(1), "y": IntegerValue(2)}), o)
+
+	_, err := get([]Value{NullValue(), IntegerValue(0)})
+	assert.EqualError(err, "not an array or object")
+
+	_, err = set([]Value{a, IntegerValue(-1), NullValue()})
+	assert.EqualError(err, "index out of range")
+
+	_, err = set([]Value{a, NumberValue(1e12), NullValue()})
+	assert.EqualError(err, "index out of range")
+
+	_, err = apush([]Value{o, NullValue()})
+	assert.EqualError(err, "not an array")
+
+	_, err = length([]Value{IntegerValue(1)})
+	assert.EqualError(err, "not an array, object or string")
+}
+
+func TestCollectionInstructions(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{StringValue("a")}},
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicArrayPush, Immediates: []Value{IntegerValue(3)}},
+		{Mnemonic: MnemonicArrayPop},
+		{Mnemonic: MnemonicPop},
+		{Mnemonic: MnemonicStore},
+		{Mnemonic: MnemonicPush, Immediates: []Value{StringValue("o"), StringValue("name"), StringValue("jsm")}},
+		{Mnemonic: MnemonicObject, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicSet, Immediates: []Value{StringValue("tags"), StringValue("vm")}},
+		{Mnemonic: MnemonicStore},
+		{Mnemonic: MnemonicLoad, Immediates: []Value{StringValue("o")}},
+		{Mnemonic: MnemonicHas, Immediates: []Value{StringValue("name")}},
+		{Mnemonic: MnemonicLoad, Immediates: []Value{StringValue("o")}},
+		{Mnemonic: MnemonicDelete, Immediates: []Value{StringValue("name")}},
+		{Mnemonic: MnemonicKeys},
+		{Mnemonic: MnemonicLoad, Immediates: []Value{StringValue("a")}},
+		{Mnemonic: MnemonicLength},
+		{Mnemonic: MnemonicLoad, Immediates: []Value{StringValue("a")}},
+		{Mnemonic: MnemonicSpread, Immediates: []Value{IntegerValue(3)}},
+		{Mnemonic: MnemonicArray, Immediates: []Value{IntegerValue(6)}},
+		{Mnemonic: MnemonicLoad, Immediates: []Value{StringValue("o")}},
+		{Mnemonic: MnemonicValues},
+		{Mnemonic: MnemonicGet, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicArray},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(3)}},
+	}
+
+	args := []Value{ArrayValue([]Value{IntegerValue(1), IntegerValue(2)})}
+	m := NewMachine()
+	res, err := m.Run(p, args)
+	assert.NoError(err)
+	assert.Equal([]Value{
+		ArrayValue([]Value{
+			BooleanValue(true),
+			ArrayValue([]Value{StringValue("tags")}),
+			IntegerValue(2),
+			IntegerValue(1),
+			IntegerValue(2),
+			NullValue(),
+		}),
+		StringValue("jsm"),
+		ArrayValue([]Value{}),
+	}, res)
+	assert.Equal([]Value{ArrayValue([]Value{IntegerValue(1), IntegerValue(2)})}, args)
+}
+
+func TestSpreadLimit(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{ArrayValue([]Value{})}},
+		{Mnemonic: MnemonicSpread, Immediates: []Value{NumberValue(1e9)}},
+		{Mnemonic: MnemonicReturn},
+	}
+
+	_, err := NewMachine(WithMaxOperands(10)).Run(p, nil)
+	assert.Equal(&OperandStackOverflowError{PC: 1, Limit: 10}, err)
+}
+
+func BenchmarkArrayPush(b *testing.B) {
+	b.ReportAllocs()
+	p := []Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{ArrayValue([]Value{})}},
+		{Label: "loop", Mnemonic: MnemonicLoadLocal, Immediates: []Value{StringValue("i")}},
+		{Mnemonic: MnemonicArrayPush},
+		{Mnemonic: MnemonicIncrementLocal, Immediates: []Value{StringValue("i")}},
+		{Mnemonic: MnemonicLoadLocal, Immediates: []Value{StringValue("i")}},
+		{Mnemonic: MnemonicLessThan, Immediates: []Value{IntegerValue(1000)}},
+		{Mnemonic: MnemonicJumpIfTrue, Immediates: []Value{StringValue("loop")}},
+		{Mnemonic: MnemonicLength},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	}
+
+	m := NewMachine()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := m.Run(p, nil); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
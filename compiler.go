@@ -0,0 +1,334 @@
+package jsm
+
+import (
+	"context"
+
+	"github.com/pkg/errors"
+)
+
+// compiled is an instruction compiled into a closure.
+type compiled func(ctx context.Context) error
+
+// compile compiles a preprocessed program into closures with pre-decoded immediates.
+// The frequently used built-in instructions are specialized to access the machine state directly,
+// and the others call their processes with the immediates.
+// Either way, a compiled instruction behaves exactly like the interpreted one.
+func (m *machine) compile(program []Instruction) []compiled {
+	cs := make([]compiled, len(program))
+	for idx := range program {
+		inst := &program[idx]
+		if _, ok := m.effects[inst.Mnemonic]; !ok && !m.replaced[inst.Mnemonic] {
+			cs[idx] = m.specialize(inst)
+		}
+		if cs[idx] == nil {
+			cs[idx] = m.generic(inst)
+		}
+	}
+	return cs
+}
+
+// chainable reports whether the compiled closures can run one after another
+// without the checks of step, that is, no hooks, gas limit or limits are set.
+func (m *machine) chainable() bool {
+	return len(m.compiled) == len(m.Program) && m.hooks == nil && m.gas.limit <= 0 && !m.limits.enabled()
+}
+
+// chain runs the compiled closures one after another until the program terminates.
+// It only accumulates the consumed gas and checks done before each instruction.
+func (m *machine) chain(ctx context.Context, done <-chan struct{}) error {
+	cs := m.compiled
+	gas := m.gas.table
+	for {
+		idx := m.PC.Index()
+		if idx < 0 || idx >= len(cs) {
+			return nil
+		}
+
+		if done != nil {
+			select {
+			case <-done:
+				return m.interrupted(ctx.Err())
+			default:
+			}
+		}
+
+		m.gas.consumed += gas[idx]
+		if err := cs[idx](m.context); err != nil {
+			re := m.runtimeError(idx, &m.Program[idx], err)
+			if !m.catch(re) {
+				return re
+			}
+		}
+	}
+}
+
+func (m *machine) generic(inst *Instruction) compiled {
+	p := *m.processor
+	oc := inst.opcode
+	if len(p) <= oc || p[oc] == nil {
+		err := errors.Errorf("cannot process %s", inst.Mnemonic)
+		return func(ctx context.Context) error {
+			return err
+		}
+	}
+
+	process := p[oc]
+	imms := inst.Immediates
+	return func(ctx context.Context) error {
+		return process(ctx, imms)
+	}
+}
+
+// specialize returns a specialized closure of an instruction, or nil if there is none.
+func (m *machine) specialize(inst *Instruction) compiled {
+	imms := inst.Immediates
+	switch inst.Mnemonic {
+	case MnemonicPush:
+		ss := toSlots(imms)
+		return func(ctx context.Context) error {
+			s, err := m.operandStack()
+			if err != nil {
+				return err
+			}
+
+			s.multiPush(ss)
+			m.PC.Increment()
+			return nil
+		}
+	case MnemonicLoadArgument:
+		if len(imms) != 1 || ToInteger(imms[0]) < 0 {
+			return nil
+		}
+
+		idx := ToInteger(imms[0])
+		return func(ctx context.Context) error {
+			f, err := m.Stack.Peek()
+			if err != nil {
+				return err
+			}
+
+			if idx >= len(f.Arguments) {
+				return errors.New("argument out of range")
+			}
+
+			f.Operands.Push(f.Arguments[idx])
+			m.PC.Increment()
+			return nil
+		}
+	case MnemonicLoadLocal:
+		if len(imms) != 1 {
+			return nil
+		}
+
+		k := ToString(imms[0])
+		return func(ctx context.Context) error {
+			f, err := m.Stack.Peek()
+			if err != nil {
+				return err
+			}
+
+			f.Operands.push(f.Locals.load(k))
+			m.PC.Increment()
+			return nil
+		}
+	case MnemonicStoreLocal:
+		if len(imms) != 2 {
+			return nil
+		}
+
+		k := ToString(imms[0])
+		v := toSlot(imms[1])
+		return func(ctx context.Context) error {
+			f, err := m.Stack.Peek()
+			if err != nil {
+				return err
+			}
+
+			f.Locals.put(k, v, f.Locals.entrySize(k, v))
+			m.PC.Increment()
+			return nil
+		}
+	case MnemonicIncrementLocal, MnemonicDecrementLocal:
+		if len(imms) != 1 {
+			return nil
+		}
+
+		k := ToString(imms[0])
+		d := 1.0
+		if inst.Mnemonic == MnemonicDecrementLocal {
+			d = -1.0
+		}
+		return func(ctx context.Context) error {
+			f, err := m.Stack.Peek()
+			if err != nil {
+				return err
+			}
+
+			v := numberSlot(f.Locals.load(k).toNumber() + d)
+			f.Locals.put(k, v, f.Locals.entrySize(k, v))
+			m.PC.Increment()
+			return nil
+		}
+	case MnemonicCall:
+		if len(imms) == 0 || ToInteger(imms[0]) < 0 {
+			return nil
+		}
+
+		addr := ToInteger(imms[0])
+		argc := 0
+		if len(imms) > 1 {
+			argc = ToInteger(imms[1])
+		}
+		if argc < 0 {
+			return nil
+		}
+		return func(ctx context.Context) error {
+			s, err := m.operandStack()
+			if err != nil {
+				return err
+			}
+
+			argv, err := s.MultiPop(argc)
+			if err != nil {
+				return errors.New("too few operands")
+			}
+
+			m.PC.Increment()
+
+			f := m.Stack.pushFrame()
+			f.Arguments = argv
+			f.ReturnTo = m.PC.Index()
+
+			m.PC.SetIndex(addr)
+			return nil
+		}
+	case MnemonicReturn:
+		n, err := getCount(imms, 0, 0)
+		if err != nil {
+			return nil
+		}
+
+		return func(ctx context.Context) error {
+			f, err := m.Stack.Peek()
+			if err != nil {
+				return err
+			}
+
+			res, err := f.Operands.multiPop(n)
+			if err != nil {
+				return errors.New("too few operands")
+			}
+
+			m.PC.SetIndex(f.ReturnTo)
+			m.Stack.Pop()
+
+			caller, err := m.Stack.Peek()
+			if err != nil {
+				setResult(m.context, ArrayValue(boxSlots(res)))
+				return nil
+			}
+
+			caller.Operands.multiPush(res)
+			return nil
+		}
+	case MnemonicJump:
+		if len(imms) == 0 || ToInteger(imms[0]) < 0 {
+			return nil
+		}
+
+		addr := ToInteger(imms[0])
+		return func(ctx context.Context) error {
+			m.PC.SetIndex(addr)
+			return nil
+		}
+	case MnemonicJumpIfTrue, MnemonicJumpIfFalse:
+		if len(imms) == 0 || ToInteger(imms[0]) < 0 {
+			return nil
+		}
+
+		addr := ToInteger(imms[0])
+		cond := inst.Mnemonic == MnemonicJumpIfTrue
+		return func(ctx context.Context) error {
+			s, err := m.operandStack()
+			if err != nil {
+				return err
+			}
+
+			v, err := s.pop()
+			if err != nil {
+				return errors.New("no operand")
+			}
+
+			if v.toBoolean() == cond {
+				m.PC.SetIndex(addr)
+			} else {
+				m.PC.Increment()
+			}
+			return nil
+		}
+	}
+
+	if op, ok := foldableOps[inst.Mnemonic]; ok {
+		arity, ok := fusibleOps[inst.Mnemonic]
+		if !ok {
+			arity = 1
+		}
+		if len(imms) > 0 && arity == 1 {
+			return nil
+		}
+		return m.operation(op, arity, imms, numberOps[inst.Mnemonic])
+	}
+	return nil
+}
+
+// numberOps are the operations evaluated fast on two unboxed numbers like the instructions created by numberOp.
+var numberOps = map[Mnemonic]func(x, y float64) slot{
+	MnemonicEqual:          eqNumbers,
+	MnemonicNotEqual:       neNumbers,
+	MnemonicGreaterThan:    gtNumbers,
+	MnemonicGreaterOrEqual: geNumbers,
+	MnemonicLessThan:       ltNumbers,
+	MnemonicLessOrEqual:    leNumbers,
+	MnemonicAdd:            addNumbers,
+	MnemonicSubtract:       subNumbers,
+	MnemonicMultiply:       mulNumbers,
+}
+
+// operation returns a closure of an operation that pushes its immediate if any
+// like the instructions created by unaryOp, binaryOp and numberOp.
+func (m *machine) operation(op func([]Value) (Value, error), arity int, imms []Value, fast func(x, y float64) slot) compiled {
+	ss := toSlots(imms)
+	return func(ctx context.Context) error {
+		s, err := m.operandStack()
+		if err != nil {
+			return err
+		}
+
+		if len(ss) > 0 {
+			s.push(ss[0])
+		}
+
+		if fast != nil && s.doNumbers(fast) {
+			m.PC.Increment()
+			return nil
+		}
+
+		if err := s.Do(op, arity); err != nil {
+			if err == errTooFewElements {
+				return errors.New("too few operands")
+			}
+			return err
+		}
+
+		m.PC.Increment()
+		return nil
+	}
+}
+
+func (m *machine) operandStack() (*stack, error) {
+	f, err := m.Stack.Peek()
+	if err != nil {
+		return nil, err
+	}
+	return f.Operands, nil
+}
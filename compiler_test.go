@@ -0,0 +1,160 @@
+package jsm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompile(t *testing.T) {
+	assert := assert.New(t)
+
+	m := newMachine()
+	assert.NoError(m.Override(MnemonicSubtract, binaryOp(sub), nil))
+
+	p, err := m.preprocessor.preprocess([]Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(-1)}},
+		{Mnemonic: MnemonicSubtract},
+		{Mnemonic: "none"},
+	})
+	assert.NoError(err)
+
+	cs := m.compile(p)
+	assert.Len(cs, 4)
+	for _, c := range cs {
+		assert.NotNil(c)
+	}
+	assert.EqualError(cs[3](m.context), "cannot process none")
+}
+
+func TestMachineCompilation(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		program []Instruction
+		args    []Value
+	}{
+		{loadExample(t, "fibonacci.json"), []Value{IntegerValue(10)}},
+		{loadExample(t, "sum_of_series.json"), []Value{IntegerValue(100)}},
+		{loadExample(t, "sum_of_series.json"), []Value{NumberValue(100000.0)}},
+		{loadExample(t, "map.json"), []Value{ArrayValue([]Value{IntegerValue(1), IntegerValue(2)})}},
+		{[]Instruction{
+			{Mnemonic: MnemonicTry, Immediates: []Value{StringValue("catch")}},
+			{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+			{Mnemonic: MnemonicCall, Immediates: []Value{StringValue("f"), IntegerValue(1)}},
+			{Mnemonic: MnemonicEndTry},
+			{Mnemonic: MnemonicPush, Immediates: []Value{StringValue("ok")}},
+			{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(2)}},
+			{Label: "catch", Mnemonic: MnemonicPush, Immediates: []Value{StringValue("caught")}},
+			{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(2)}},
+			{Label: "f", Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+			{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+			{Mnemonic: MnemonicDivide},
+			{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		}, []Value{IntegerValue(0)}},
+		{[]Instruction{
+			{Mnemonic: MnemonicStoreLocal, Immediates: []Value{StringValue("x"), IntegerValue(1)}},
+			{Mnemonic: MnemonicDecrementLocal, Immediates: []Value{StringValue("x")}},
+			{Mnemonic: MnemonicLoadLocal, Immediates: []Value{StringValue("x")}},
+			{Mnemonic: MnemonicJumpIfFalse, Immediates: []Value{StringValue("end")}},
+			{Mnemonic: MnemonicIncrementLocal, Immediates: []Value{StringValue("x")}},
+			{Label: "end", Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(1)}},
+			{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		}, []Value{IntegerValue(0)}},
+		{[]Instruction{
+			{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+			{Mnemonic: MnemonicCall, Immediates: []Value{StringValue("f"), IntegerValue(1)}},
+			{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(2)}},
+			{Mnemonic: MnemonicCall, Immediates: []Value{StringValue("f"), IntegerValue(1)}},
+			{Mnemonic: MnemonicCallValue},
+			{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(4)}},
+			{Label: "f", Mnemonic: MnemonicLoadLocal, Immediates: []Value{StringValue("x")}},
+			{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+			{Mnemonic: MnemonicStoreLocal, Immediates: []Value{StringValue("x")}},
+			{Mnemonic: MnemonicClosure, Immediates: []Value{StringValue("g")}},
+			{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(2)}},
+			{Label: "g", Mnemonic: MnemonicLoadCaptured, Immediates: []Value{StringValue("x")}},
+			{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		}, nil},
+		{[]Instruction{
+			{Mnemonic: MnemonicPush, Immediates: []Value{StringValue("a")}},
+			{Mnemonic: MnemonicConcat, Immediates: []Value{StringValue("b")}},
+			{Mnemonic: MnemonicStringLength},
+			{Mnemonic: MnemonicLessThan, Immediates: []Value{IntegerValue(3)}},
+			{Mnemonic: "none"},
+		}, nil},
+	}
+
+	for i, test := range tests {
+		m1 := NewMachine()
+		res1, err1 := m1.Run(test.program, test.args)
+
+		m2 := NewMachine(WithCompilation())
+		res2, err2 := m2.Run(test.program, test.args)
+
+		assert.Equal(res1, res2, i)
+		assert.Equal(errorString(err1), errorString(err2), i)
+		assert.Equal(m1.ConsumedGas(), m2.ConsumedGas(), i)
+
+		d1, err := m1.Dump()
+		assert.NoError(err)
+		d2, err := m2.Dump()
+		assert.NoError(err)
+		assert.JSONEq(string(d1), string(d2), i)
+	}
+}
+
+func TestMachineCompilationContext(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicNop},
+		{Label: "loop", Mnemonic: MnemonicJump, Immediates: []Value{StringValue("loop")}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	m := NewMachine(WithCompilation())
+	_, err := m.RunContext(ctx, p, nil)
+
+	var ie *InterruptedError
+	assert.ErrorAs(err, &ie)
+	assert.Equal(1, ie.PC)
+	assert.Equal(context.DeadlineExceeded, ie.Err)
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = m.RunContext(ctx, p, nil)
+	assert.ErrorAs(err, &ie)
+	assert.Equal(0, ie.PC)
+	assert.Equal(context.Canceled, ie.Err)
+}
+
+// errorString returns the message of an error with the details of a runtime error.
+func errorString(err error) string {
+	if re, ok := err.(*RuntimeError); ok {
+		return fmt.Sprintf("%+v", re)
+	}
+	return fmt.Sprint(err)
+}
+
+func BenchmarkFibCompiled(b *testing.B) {
+	b.ReportAllocs()
+	m := NewMachine(WithCompilation())
+
+	var p []Instruction
+	j, _ := ioutil.ReadFile("./examples/fibonacci.json")
+	json.Unmarshal(j, &p)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		m.Run(p, []Value{NumberValue(20.0)})
+	}
+}
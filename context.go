@@ -2,6 +2,7 @@ package jsm
 
 import (
 	"context"
+	"regexp"
 	"time"
 
 	"github.com/pkg/errors"
@@ -14,26 +15,56 @@ const (
 	keyHeap
 	keyStack
 	keyResult
+	keyParent
+	keyProgram
 )
 
-type machineContext map[machineContextKey]interface{}
+// machineContext is the context passed to instructions.
+// Its values are held in fields rather than a map since they are retrieved by every instruction.
+type machineContext struct {
+	pc      *programCounter
+	heap    *heap
+	stack   *callStack
+	result  Value
+	parent  context.Context
+	program *[]Instruction
+	limits  *limits
+
+	// regexps are the regular expressions compiled from the immediates of match.
+	regexps map[string]*regexp.Regexp
+}
 
 func (mc *machineContext) Deadline() (deadline time.Time, ok bool) {
-	return
+	return mc.parent.Deadline()
 }
 
 func (mc *machineContext) Done() <-chan struct{} {
-	return nil
+	return mc.parent.Done()
 }
 
 func (mc *machineContext) Err() error {
-	return nil
+	return mc.parent.Err()
 }
 
 func (mc *machineContext) Value(key interface{}) interface{} {
-	switch key.(type) {
-	case machineContextKey:
-		return (*mc)[key.(machineContextKey)]
+	k, ok := key.(machineContextKey)
+	if !ok {
+		return mc.parent.Value(key)
+	}
+
+	switch k {
+	case keyPC:
+		return mc.pc
+	case keyHeap:
+		return mc.heap
+	case keyStack:
+		return mc.stack
+	case keyResult:
+		return &mc.result
+	case keyParent:
+		return mc.parent
+	case keyProgram:
+		return mc.program
 	default:
 		return nil
 	}
@@ -41,25 +72,39 @@ func (mc *machineContext) Value(key interface{}) interface{} {
 
 func newMachineContext(m *machine) context.Context {
 	return &machineContext{
-		keyPC:     m.PC,
-		keyHeap:   m.Heap,
-		keyStack:  m.Stack,
-		keyResult: new(Value),
+		pc:      m.PC,
+		heap:    m.Heap,
+		stack:   m.Stack,
+		parent:  context.Background(),
+		program: &m.Program,
+		limits:  m.limits,
 	}
 }
 
+func setParent(ctx context.Context, parent context.Context) {
+	ctx.(*machineContext).parent = parent
+}
+
 // GetProgramCounter retrieves the program counter.
 func GetProgramCounter(ctx context.Context) ProgramCounter {
-	return (*ctx.(*machineContext))[keyPC].(*programCounter)
+	return ctx.(*machineContext).pc
 }
 
 // GetGlobalHeap retrieves the global heap.
 func GetGlobalHeap(ctx context.Context) Heap {
-	return (*ctx.(*machineContext))[keyHeap].(*heap)
+	return ctx.(*machineContext).heap
+}
+
+func getProgram(ctx context.Context) []Instruction {
+	return *ctx.(*machineContext).program
+}
+
+func getLimits(ctx context.Context) *limits {
+	return ctx.(*machineContext).limits
 }
 
 func getCallStack(ctx context.Context) *callStack {
-	return (*ctx.(*machineContext))[keyStack].(*callStack)
+	return ctx.(*machineContext).stack
 }
 
 func getFrame(ctx context.Context) (*frame, error) {
@@ -81,7 +126,21 @@ func GetArgument(ctx context.Context, idx int) (Value, error) {
 }
 
 // GetLocalHeap retrieves the current local heap.
+// The heap must not be used after the current function returns,
+// since the machine clears and reuses it for a later call.
 func GetLocalHeap(ctx context.Context) (Heap, error) {
+	lh, err := getLocalHeap(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return lh, nil
+}
+
+func getGlobalHeap(ctx context.Context) *heap {
+	return ctx.(*machineContext).heap
+}
+
+func getLocalHeap(ctx context.Context) (*heap, error) {
 	f, err := getFrame(ctx)
 	if err != nil {
 		return nil, err
@@ -90,7 +149,17 @@ func GetLocalHeap(ctx context.Context) (Heap, error) {
 }
 
 // GetOperandStack retrieves the current operand stack.
+// The stack must not be used after the current function returns,
+// since the machine clears and reuses it for a later call.
 func GetOperandStack(ctx context.Context) (Stack, error) {
+	s, err := getOperandStack(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+func getOperandStack(ctx context.Context) (*stack, error) {
 	f, err := getFrame(ctx)
 	if err != nil {
 		return nil, err
@@ -99,12 +168,25 @@ func GetOperandStack(ctx context.Context) (Stack, error) {
 }
 
 func getResult(ctx context.Context) Value {
-	return *(*ctx.(*machineContext))[keyResult].(*Value)
+	return ctx.(*machineContext).result
 }
 
 func setResult(ctx context.Context, res Value) {
-	r := (*ctx.(*machineContext))[keyResult].(*Value)
-	*r = res
+	ctx.(*machineContext).result = res
+}
+
+// getRegexps retrieves the regular expressions compiled from the immediates of match,
+// which are kept until the machine is cleared.
+func getRegexps(ctx context.Context) map[string]*regexp.Regexp {
+	mc := ctx.(*machineContext)
+	if mc.regexps == nil {
+		mc.regexps = map[string]*regexp.Regexp{}
+	}
+	return mc.regexps
+}
+
+func clearRegexps(ctx context.Context) {
+	ctx.(*machineContext).regexps = nil
 }
 
 type programContextKey int
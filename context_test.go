@@ -1,7 +1,9 @@
 package jsm
 
 import (
+	"context"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -19,6 +21,29 @@ func TestMachineContext(t *testing.T) {
 	assert.Equal(3, ToInteger(*mc.Value(keyResult).(*Value)))
 }
 
+func TestMachineContextParent(t *testing.T) {
+	assert := assert.New(t)
+
+	mc := newMachineContext(newMachine())
+	assert.Nil(mc.Done())
+	assert.NoError(mc.Err())
+	_, ok := mc.Deadline()
+	assert.False(ok)
+
+	type key struct{}
+	d := time.Now().Add(time.Hour)
+	parent, cancel := context.WithDeadline(context.WithValue(context.Background(), key{}, "abc"), d)
+	setParent(mc, parent)
+	assert.Equal("abc", mc.Value(key{}))
+	assert.Equal(parent.Done(), mc.Done())
+	deadline, ok := mc.Deadline()
+	assert.True(ok)
+	assert.Equal(d, deadline)
+
+	cancel()
+	assert.Equal(context.Canceled, mc.Err())
+}
+
 func TestProgramContext(t *testing.T) {
 	assert := assert.New(t)
 
@@ -0,0 +1,69 @@
+package jsm
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
+
+func typeof(vs []Value) (Value, error) {
+	return StringValue(TypeOf(vs[0]).String()), nil
+}
+
+func tobool(vs []Value) (Value, error) {
+	return BooleanValue(ToBoolean(vs[0])), nil
+}
+
+func toint(vs []Value) (Value, error) {
+	return IntegerValue(ToInteger(vs[0])), nil
+}
+
+func tonum(vs []Value) (Value, error) {
+	return NumberValue(ToNumber(vs[0])), nil
+}
+
+func tostr(vs []Value) (Value, error) {
+	return StringValue(ToString(vs[0])), nil
+}
+
+func isType(t Type) func([]Value) (Value, error) {
+	return func(vs []Value) (Value, error) {
+		return BooleanValue(TypeOf(vs[0]) == t), nil
+	}
+}
+
+// parse decodes a JSON text into a value whose arrays and objects are []Value and map[string]Value.
+func parse(vs []Value) (Value, error) {
+	var v Value
+	if err := json.Unmarshal([]byte(ToString(vs[0])), &v); err != nil {
+		return NullValue(), errors.Wrap(err, "invalid json")
+	}
+	return normalize(v), nil
+}
+
+var parseOperand = unaryOp(parse)
+
+// parseOp rejects a JSON text longer than the bytes that the heaps can hold
+// before decoding it, since the decoded value is at least as large as the text.
+func parseOp(ctx context.Context, imms []Value) error {
+	max := getLimits(ctx).maxHeapBytes
+	if max <= 0 {
+		return parseOperand(ctx, imms)
+	}
+
+	return unaryOp(func(vs []Value) (Value, error) {
+		if len(ToString(vs[0])) > max {
+			return NullValue(), errors.Errorf("json exceeds %d bytes", max)
+		}
+		return parse(vs)
+	})(ctx, imms)
+}
+
+func stringify(vs []Value) (Value, error) {
+	data, err := json.Marshal(normalize(vs[0]))
+	if err != nil {
+		return NullValue(), errors.Wrap(err, "cannot convert to json")
+	}
+	return StringValue(string(data)), nil
+}
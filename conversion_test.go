@@ -0,0 +1,103 @@
+package jsm
+
+import (
+	"math"
+	"testing"
+	"unsafe"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTypeString(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("undefined", TypeUndefined.String())
+	assert.Equal("null", TypeNull.String())
+	assert.Equal("boolean", TypeBoolean.String())
+	assert.Equal("number", TypeNumber.String())
+	assert.Equal("string", TypeString.String())
+	assert.Equal("array", TypeArray.String())
+	assert.Equal("object", TypeObject.String())
+	assert.Equal("pointer", TypePointer.String())
+	assert.Equal("function", TypeFunction.String())
+	assert.Equal("undefined", Type(-1).String())
+}
+
+func TestConversionOperations(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		op  func([]Value) (Value, error)
+		v   Value
+		res Value
+	}{
+		{typeof, NullValue(), StringValue("null")},
+		{typeof, IntegerValue(1), StringValue("number")},
+		{typeof, ArrayValue([]Value{}), StringValue("array")},
+		{typeof, PointerValue(unsafe.Pointer(&t)), StringValue("pointer")},
+		{tobool, StringValue(""), BooleanValue(false)},
+		{tobool, ObjectValue(map[string]Value{}), BooleanValue(true)},
+		{toint, StringValue("1.5"), IntegerValue(1)},
+		{tonum, StringValue("1.5"), NumberValue(1.5)},
+		{tonum, BooleanValue(true), NumberValue(1.0)},
+		{tostr, NumberValue(math.Inf(-1)), StringValue("-Infinity")},
+		{tostr, ArrayValue([]Value{IntegerValue(1), StringValue("a")}), StringValue("1,a")},
+		{isType(TypeNull), ArrayValue(nil), BooleanValue(true)},
+		{isType(TypeArray), []string{}, BooleanValue(true)},
+		{isType(TypeObject), ArrayValue([]Value{}), BooleanValue(false)},
+		{parse, StringValue(`{"a":[1,"b",null]}`), ObjectValue(map[string]Value{
+			"a": ArrayValue([]Value{NumberValue(1.0), StringValue("b"), NullValue()}),
+		})},
+		{stringify, ObjectValue(map[string]Value{"a": IntegerValue(1), "b": StringValue("c")}), StringValue(`{"a":1,"b":"c"}`)},
+		{stringify, StringValue("a"), StringValue(`"a"`)},
+	}
+
+	for _, test := range tests {
+		res, err := test.op([]Value{test.v})
+		assert.NoError(err)
+		assert.Equal(test.res, res, "%v", test.v)
+	}
+
+	_, err := parse([]Value{StringValue("{")})
+	assert.Error(err)
+
+	_, err = stringify([]Value{NumberValue(math.NaN())})
+	assert.Error(err)
+}
+
+func TestConversionInstructions(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicTypeOf},
+		{Mnemonic: MnemonicEqual, Immediates: []Value{StringValue("string")}},
+		{Mnemonic: MnemonicJumpIfFalse, Immediates: []Value{StringValue("other")}},
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicParse},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		{Label: "other", Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicStringify},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	}
+
+	m := NewMachine()
+	res, err := m.Run(p, []Value{StringValue("[1,2]")})
+	assert.NoError(err)
+	assert.Equal([]Value{ArrayValue([]Value{NumberValue(1.0), NumberValue(2.0)})}, res)
+
+	res, err = m.Run(p, []Value{ArrayValue([]Value{IntegerValue(1), IntegerValue(2)})})
+	assert.NoError(err)
+	assert.Equal([]Value{StringValue("[1,2]")}, res)
+
+	_, err = m.Run(p, []Value{StringValue("[1,")})
+	assert.EqualError(err, "parse at 5: invalid json: unexpected end of JSON input")
+
+	m = NewMachine(WithMaxHeapBytes(10))
+	res, err = m.Run(p, []Value{StringValue("[1,2]")})
+	assert.NoError(err)
+	assert.Equal([]Value{ArrayValue([]Value{NumberValue(1.0), NumberValue(2.0)})}, res)
+
+	_, err = m.Run(p, []Value{StringValue("[1,2,3,4,5,6]")})
+	assert.EqualError(err, "parse at 5: json exceeds 10 bytes")
+}
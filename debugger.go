@@ -0,0 +1,301 @@
+package jsm
+
+import (
+	"github.com/pkg/errors"
+)
+
+// Debugger executes a program on a Machine step by step.
+type Debugger interface {
+	// Load loads a program with its arguments and
+	// stops before the first instruction.
+	Load(program []Instruction, args []Value) error
+
+	// Step executes the current instruction.
+	Step() (Stop, error)
+
+	// StepOver is like Step, but runs a called function to its return.
+	StepOver() (Stop, error)
+
+	// StepOut runs until the current frame returns.
+	StepOut() (Stop, error)
+
+	// Continue runs until a breakpoint or a watchpoint is hit,
+	// or the program terminates.
+	Continue() (Stop, error)
+
+	// SetBreakpoint sets a breakpoint at the instruction
+	// specified by its index or label.
+	SetBreakpoint(location Value) error
+
+	// ClearBreakpoint clears the breakpoint at the instruction
+	// specified by its index or label.
+	ClearBreakpoint(location Value) error
+
+	// Breakpoints returns the indices of the instructions with breakpoints.
+	Breakpoints() []int
+
+	// Watch sets a watchpoint on the specified key of the global heap.
+	Watch(key string)
+
+	// Unwatch clears the watchpoint on the specified key of the global heap.
+	Unwatch(key string)
+
+	// PC returns the index of the current instruction.
+	PC() int
+
+	// Instruction returns the current instruction as written in the program.
+	// It returns false if the program has terminated.
+	Instruction() (Instruction, bool)
+
+	// CallStack returns a snapshot of the call stack from the bottom to the top.
+	CallStack() []Frame
+
+	// Done reports whether the program has terminated.
+	Done() bool
+
+	// Result returns the result of the terminated program.
+	Result() Value
+}
+
+// Frame is a snapshot of a frame of the call stack.
+type Frame struct {
+	Arguments []Value
+	Locals    map[string]Value
+	Operands  []Value
+	ReturnTo  int
+}
+
+// StopReason is the reason why a Debugger stopped.
+type StopReason int
+
+// These constants are the reasons why a Debugger stopped.
+const (
+	StopStep StopReason = iota
+	StopBreakpoint
+	StopWatchpoint
+	StopTerminated
+)
+
+// Stop describes where and why a Debugger stopped.
+type Stop struct {
+	Reason StopReason
+
+	// PC is the index of the instruction to be executed next.
+	PC int
+
+	// Key is the key of the global heap whose value has changed
+	// if the reason is StopWatchpoint.
+	Key string
+
+	// Old and New are the values before and after the change
+	// if the reason is StopWatchpoint.
+	Old, New Value
+}
+
+// NewDebugger creates a new Debugger on the specified Machine,
+// which must be created by NewMachine.
+func NewDebugger(m Machine) Debugger {
+	return &debugger{
+		machine:     m.(*machine),
+		breakpoints: map[int]bool{},
+		watches:     map[string]Value{},
+	}
+}
+
+type debugger struct {
+	machine     *machine
+	source      []Instruction
+	labels      map[string]int
+	breakpoints map[int]bool
+	watches     map[string]Value
+	err         error
+}
+
+func (d *debugger) Load(program []Instruction, args []Value) error {
+	if err := d.machine.load(program, args, false); err != nil {
+		return err
+	}
+
+	d.source = program
+	d.labels = map[string]int{}
+	for idx, inst := range program {
+		if inst.Label != "" {
+			d.labels[inst.Label] = idx
+		}
+	}
+	d.breakpoints = map[int]bool{}
+	for k := range d.watches {
+		d.watches[k] = d.load(k)
+	}
+	d.err = nil
+	return nil
+}
+
+func (d *debugger) Step() (Stop, error) {
+	if stop, ok, err := d.step(); ok || err != nil {
+		return stop, err
+	}
+	return d.stop(StopStep), nil
+}
+
+func (d *debugger) StepOver() (Stop, error) {
+	return d.runWhile(len(*d.machine.Stack), func(depth, start int) bool {
+		return depth > start
+	})
+}
+
+func (d *debugger) StepOut() (Stop, error) {
+	return d.runWhile(len(*d.machine.Stack), func(depth, start int) bool {
+		return depth >= start
+	})
+}
+
+func (d *debugger) Continue() (Stop, error) {
+	return d.runWhile(0, func(depth, start int) bool {
+		return true
+	})
+}
+
+func (d *debugger) runWhile(start int, cond func(depth, start int) bool) (Stop, error) {
+	for {
+		if stop, ok, err := d.step(); ok || err != nil {
+			return stop, err
+		}
+
+		if !cond(len(*d.machine.Stack), start) {
+			return d.stop(StopStep), nil
+		}
+
+		if d.breakpoints[d.machine.PC.Index()] {
+			return d.stop(StopBreakpoint), nil
+		}
+	}
+}
+
+// step executes the current instruction and reports whether the debugger must stop.
+func (d *debugger) step() (Stop, bool, error) {
+	if d.err != nil {
+		return d.stop(StopStep), true, d.err
+	}
+
+	if d.Done() {
+		return d.stop(StopTerminated), true, nil
+	}
+
+	if err := d.machine.step(); err != nil {
+		d.err = err
+		return d.stop(StopStep), true, err
+	}
+
+	if d.Done() {
+		return d.stop(StopTerminated), true, nil
+	}
+
+	for k, old := range d.watches {
+		v := d.load(k)
+		if !Equal(old, v) {
+			d.watches[k] = v
+			stop := d.stop(StopWatchpoint)
+			stop.Key = k
+			stop.Old = old
+			stop.New = v
+			return stop, true, nil
+		}
+	}
+	return Stop{}, false, nil
+}
+
+func (d *debugger) stop(reason StopReason) Stop {
+	return Stop{Reason: reason, PC: d.machine.PC.Index()}
+}
+
+func (d *debugger) load(key string) Value {
+	v, _ := d.machine.Heap.Load(key)
+	return v
+}
+
+func (d *debugger) location(loc Value) (int, error) {
+	if TypeOf(loc) == TypeString {
+		idx, ok := d.labels[ToString(loc)]
+		if !ok {
+			return -1, errors.Errorf("undefined label: %s", ToString(loc))
+		}
+		return idx, nil
+	}
+
+	idx := ToInteger(loc)
+	if idx < 0 || idx >= len(d.source) {
+		return -1, errors.Errorf("invalid address: %d", idx)
+	}
+	return idx, nil
+}
+
+func (d *debugger) SetBreakpoint(loc Value) error {
+	idx, err := d.location(loc)
+	if err != nil {
+		return err
+	}
+
+	d.breakpoints[idx] = true
+	return nil
+}
+
+func (d *debugger) ClearBreakpoint(loc Value) error {
+	idx, err := d.location(loc)
+	if err != nil {
+		return err
+	}
+
+	delete(d.breakpoints, idx)
+	return nil
+}
+
+func (d *debugger) Breakpoints() []int {
+	var bps []int
+	for idx := range d.source {
+		if d.breakpoints[idx] {
+			bps = append(bps, idx)
+		}
+	}
+	return bps
+}
+
+func (d *debugger) Watch(key string) {
+	d.watches[key] = d.load(key)
+}
+
+func (d *debugger) Unwatch(key string) {
+	delete(d.watches, key)
+}
+
+func (d *debugger) PC() int {
+	return d.machine.PC.Index()
+}
+
+func (d *debugger) Instruction() (Instruction, bool) {
+	if d.Done() {
+		return Instruction{}, false
+	}
+	return d.source[d.machine.PC.Index()], true
+}
+
+func (d *debugger) CallStack() []Frame {
+	frames := make([]Frame, len(*d.machine.Stack))
+	for i, f := range *d.machine.Stack {
+		frames[i] = Frame{
+			Arguments: append([]Value{}, f.Arguments...),
+			Locals:    f.Locals.toObject(),
+			Operands:  f.Operands.toValues(),
+			ReturnTo:  f.ReturnTo,
+		}
+	}
+	return frames
+}
+
+func (d *debugger) Done() bool {
+	return d.source == nil || !d.machine.inProgress()
+}
+
+func (d *debugger) Result() Value {
+	return getResult(d.machine.context)
+}
@@ -0,0 +1,157 @@
+package jsm
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func loadExample(t *testing.T, name string) []Instruction {
+	j, err := ioutil.ReadFile("./examples/" + name)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var p []Instruction
+	if err := json.Unmarshal(j, &p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestDebuggerStep(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewDebugger(NewMachine())
+	assert.True(d.Done())
+
+	err := d.Load(loadExample(t, "fibonacci.json"), []Value{NumberValue(3.0)})
+	assert.NoError(err)
+	assert.False(d.Done())
+
+	inst, ok := d.Instruction()
+	assert.True(ok)
+	assert.Equal("fib", inst.Label)
+
+	stop, err := d.Step()
+	assert.NoError(err)
+	assert.Equal(Stop{Reason: StopStep, PC: 1}, stop)
+	cs := d.CallStack()
+	assert.Len(cs, 1)
+	assert.Equal([]Value{NumberValue(3.0)}, cs[0].Operands)
+
+	for i := 0; i < 4; i++ {
+		_, err = d.Step()
+		assert.NoError(err)
+	}
+	assert.Equal(5, d.PC())
+
+	stop, err = d.StepOver()
+	assert.NoError(err)
+	assert.Equal(Stop{Reason: StopStep, PC: 6}, stop)
+	cs = d.CallStack()
+	assert.Len(cs, 1)
+	assert.Equal([]Value{NumberValue(1.0)}, cs[0].Operands)
+
+	for i := 0; i < 2; i++ {
+		_, err = d.Step()
+		assert.NoError(err)
+	}
+
+	stop, err = d.Step()
+	assert.NoError(err)
+	assert.Equal(Stop{Reason: StopStep, PC: 0}, stop)
+	cs = d.CallStack()
+	assert.Len(cs, 2)
+	assert.Equal([]Value{NumberValue(1.0)}, cs[1].Arguments)
+	assert.Equal(9, cs[1].ReturnTo)
+
+	stop, err = d.StepOut()
+	assert.NoError(err)
+	assert.Equal(Stop{Reason: StopStep, PC: 9}, stop)
+	assert.Len(d.CallStack(), 1)
+
+	stop, err = d.Continue()
+	assert.NoError(err)
+	assert.Equal(Stop{Reason: StopTerminated, PC: 13}, stop)
+	assert.True(d.Done())
+	assert.Equal([]Value{NumberValue(2.0)}, d.Result())
+
+	_, ok = d.Instruction()
+	assert.False(ok)
+
+	stop, err = d.Step()
+	assert.NoError(err)
+	assert.Equal(StopTerminated, stop.Reason)
+}
+
+func TestDebuggerBreakpoint(t *testing.T) {
+	assert := assert.New(t)
+
+	d := NewDebugger(NewMachine())
+	err := d.Load(loadExample(t, "fibonacci.json"), []Value{NumberValue(3.0)})
+	assert.NoError(err)
+
+	assert.NoError(d.SetBreakpoint(StringValue("init")))
+	assert.NoError(d.SetBreakpoint(IntegerValue(10)))
+	assert.Error(d.SetBreakpoint(StringValue("none")))
+	assert.Error(d.SetBreakpoint(IntegerValue(13)))
+	assert.Equal([]int{10, 11}, d.Breakpoints())
+
+	stop, err := d.Continue()
+	assert.NoError(err)
+	assert.Equal(Stop{Reason: StopBreakpoint, PC: 11}, stop)
+	assert.Len(d.CallStack(), 3)
+
+	stop, err = d.StepOver()
+	assert.NoError(err)
+	assert.Equal(Stop{Reason: StopStep, PC: 12}, stop)
+
+	assert.NoError(d.ClearBreakpoint(StringValue("init")))
+	assert.Equal([]int{10}, d.Breakpoints())
+
+	stop, err = d.StepOut()
+	assert.NoError(err)
+	assert.Equal(Stop{Reason: StopStep, PC: 6}, stop)
+
+	stop, err = d.Continue()
+	assert.NoError(err)
+	assert.Equal(Stop{Reason: StopBreakpoint, PC: 10}, stop)
+	assert.Len(d.CallStack(), 2)
+}
+
+func TestDebuggerWatch(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicStore, Immediates: []Value{StringValue("a"), IntegerValue(1)}},
+		{Mnemonic: MnemonicStore, Immediates: []Value{StringValue("b"), IntegerValue(2)}},
+		{Mnemonic: MnemonicStore, Immediates: []Value{StringValue("a"), NumberValue(1.0)}},
+		{Mnemonic: MnemonicIncrement, Immediates: []Value{StringValue("a")}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicDivide, Immediates: []Value{IntegerValue(0)}},
+	}
+
+	d := NewDebugger(NewMachine())
+	d.Watch("a")
+	err := d.Load(p, nil)
+	assert.NoError(err)
+
+	stop, err := d.Continue()
+	assert.NoError(err)
+	assert.Equal(Stop{Reason: StopWatchpoint, PC: 1, Key: "a", Old: nil, New: IntegerValue(1)}, stop)
+
+	stop, err = d.Continue()
+	assert.NoError(err)
+	assert.Equal(Stop{Reason: StopWatchpoint, PC: 4, Key: "a", Old: IntegerValue(1), New: NumberValue(2.0)}, stop)
+
+	d.Unwatch("a")
+	_, err = d.Continue()
+	assert.EqualError(err, "div at 5: divide by zero")
+	assert.Equal(5, d.PC())
+
+	_, err = d.Step()
+	assert.EqualError(err, "div at 5: divide by zero")
+}
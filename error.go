@@ -0,0 +1,193 @@
+package jsm
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// InterruptedError is returned when the execution of a program is interrupted
+// because the context passed to RunContext is done.
+type InterruptedError struct {
+	// PC is the index of the instruction that was about to be executed.
+	PC int
+
+	// Depth is the depth of the call stack at the point of interruption.
+	Depth int
+
+	// Err is the error returned by the Err method of the context.
+	Err error
+}
+
+func (e *InterruptedError) Error() string {
+	return fmt.Sprintf("interrupted at %d (depth %d): %v", e.PC, e.Depth, e.Err)
+}
+
+// Cause returns the underlying context error.
+func (e *InterruptedError) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns the underlying context error.
+func (e *InterruptedError) Unwrap() error {
+	return e.Err
+}
+
+// OutOfGasError is returned when a program tries to consume more gas
+// than the limit set by WithGasLimit.
+type OutOfGasError struct {
+	// PC is the index of the instruction that could not be executed.
+	PC int
+
+	// Limit is the gas limit.
+	Limit int
+
+	// Consumed is the amount of gas consumed before the interruption.
+	Consumed int
+}
+
+func (e *OutOfGasError) Error() string {
+	return fmt.Sprintf("out of gas at %d: consumed %d of %d", e.PC, e.Consumed, e.Limit)
+}
+
+// CallStackOverflowError is returned when the depth of the call stack
+// exceeds the limit set by WithMaxCallDepth.
+type CallStackOverflowError struct {
+	// PC is the index of the instruction that caused the overflow.
+	PC int
+
+	// Limit is the maximum depth of the call stack.
+	Limit int
+}
+
+func (e *CallStackOverflowError) Error() string {
+	return fmt.Sprintf("call stack overflow at %d: depth exceeds %d", e.PC, e.Limit)
+}
+
+// OperandStackOverflowError is returned when the size of an operand stack
+// exceeds the limit set by WithMaxOperands.
+type OperandStackOverflowError struct {
+	// PC is the index of the instruction that caused the overflow.
+	PC int
+
+	// Limit is the maximum size of an operand stack.
+	Limit int
+}
+
+func (e *OperandStackOverflowError) Error() string {
+	return fmt.Sprintf("operand stack overflow at %d: size exceeds %d", e.PC, e.Limit)
+}
+
+// HeapExhaustedError is returned when the heaps exceed the limits
+// set by WithMaxHeapKeys or WithMaxHeapBytes.
+type HeapExhaustedError struct {
+	// PC is the index of the instruction that exhausted the heaps.
+	PC int
+
+	// Keys is the total number of keys in the heaps.
+	Keys int
+
+	// Bytes is the approximate total number of bytes used by the heaps.
+	Bytes int
+
+	// MaxKeys is the maximum number of keys, or zero if unlimited.
+	MaxKeys int
+
+	// MaxBytes is the maximum number of bytes, or zero if unlimited.
+	MaxBytes int
+}
+
+func (e *HeapExhaustedError) Error() string {
+	return fmt.Sprintf("heap exhausted at %d: %d keys, %d bytes", e.PC, e.Keys, e.Bytes)
+}
+
+// RuntimeError is returned when an instruction fails.
+type RuntimeError struct {
+	// Err is the error returned by the instruction.
+	Err error
+
+	// PC is the index of the failed instruction.
+	PC int
+
+	// Mnemonic is the mnemonic of the failed instruction.
+	Mnemonic Mnemonic
+
+	// Label is the nearest label at or before the failed instruction.
+	Label string
+
+	// Comment is the comment of the failed instruction.
+	Comment string
+
+	// StackTrace is the call stack at the time of the failure.
+	// The first location is that of the failed instruction
+	// and the others are those of the calling instructions.
+	StackTrace []Location
+}
+
+// Location is a location in a program.
+type Location struct {
+	// PC is the index of an instruction.
+	PC int
+
+	// Label is the nearest label at or before the instruction.
+	Label string
+}
+
+func (l Location) String() string {
+	if l.Label == "" {
+		return fmt.Sprintf("%d", l.PC)
+	}
+	return fmt.Sprintf("%d (%s)", l.PC, l.Label)
+}
+
+func (e *RuntimeError) Error() string {
+	loc := Location{PC: e.PC, Label: e.Label}
+	return fmt.Sprintf("%s at %s: %v", e.Mnemonic, loc, e.Err)
+}
+
+// Cause returns the error returned by the instruction.
+func (e *RuntimeError) Cause() error {
+	return e.Err
+}
+
+// Unwrap returns the error returned by the instruction.
+func (e *RuntimeError) Unwrap() error {
+	return e.Err
+}
+
+// Format formats the error. The %+v verb also prints the comment
+// of the failed instruction and the stack trace.
+func (e *RuntimeError) Format(s fmt.State, verb rune) {
+	switch verb {
+	case 'v':
+		if s.Flag('+') {
+			io.WriteString(s, e.Error())
+			if e.Comment != "" {
+				fmt.Fprintf(s, " ; %s", e.Comment)
+			}
+			for _, loc := range e.StackTrace {
+				fmt.Fprintf(s, "\n\tat %s", loc)
+			}
+			return
+		}
+		fallthrough
+	case 's':
+		io.WriteString(s, e.Error())
+	case 'q':
+		fmt.Fprintf(s, "%q", e.Error())
+	}
+}
+
+// ThrownError is the error of the throw instruction
+// that carries the thrown value.
+type ThrownError struct {
+	Value Value
+}
+
+func (e *ThrownError) Error() string {
+	data, err := json.Marshal(e.Value)
+	if err != nil {
+		return fmt.Sprintf("uncaught exception: %s", ToString(e.Value))
+	}
+	return fmt.Sprintf("uncaught exception: %s", data)
+}
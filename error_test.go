@@ -0,0 +1,57 @@
+package jsm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInterruptedError(t *testing.T) {
+	assert := assert.New(t)
+
+	var err error = &InterruptedError{PC: 3, Depth: 2, Err: context.Canceled}
+	assert.Equal("interrupted at 3 (depth 2): context canceled", err.Error())
+	assert.Equal(context.Canceled, errors.Cause(err))
+	assert.True(errors.Is(err, context.Canceled))
+}
+
+func TestOutOfGasError(t *testing.T) {
+	assert := assert.New(t)
+
+	err := &OutOfGasError{PC: 3, Limit: 10, Consumed: 9}
+	assert.Equal("out of gas at 3: consumed 9 of 10", err.Error())
+}
+
+func TestLimitErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal("call stack overflow at 1: depth exceeds 10",
+		(&CallStackOverflowError{PC: 1, Limit: 10}).Error())
+	assert.Equal("operand stack overflow at 2: size exceeds 20",
+		(&OperandStackOverflowError{PC: 2, Limit: 20}).Error())
+	assert.Equal("heap exhausted at 3: 4 keys, 100 bytes",
+		(&HeapExhaustedError{PC: 3, Keys: 4, Bytes: 100, MaxKeys: 3}).Error())
+}
+
+func TestRuntimeError(t *testing.T) {
+	assert := assert.New(t)
+
+	err := &RuntimeError{
+		Err:      errors.New("divide by zero"),
+		PC:       9,
+		Mnemonic: MnemonicDivide,
+		Label:    "f",
+		Comment:  "x / y",
+		StackTrace: []Location{
+			{PC: 9, Label: "f"},
+			{PC: 3},
+		},
+	}
+	assert.Equal("div at 9 (f): divide by zero", err.Error())
+	assert.Equal("div at 9 (f): divide by zero", fmt.Sprintf("%v", err))
+	assert.Equal("div at 9 (f): divide by zero ; x / y\n\tat 9 (f)\n\tat 3", fmt.Sprintf("%+v", err))
+	assert.Equal("divide by zero", errors.Cause(err).Error())
+}
@@ -0,0 +1,42 @@
+package jsm
+
+// catch transfers control to the innermost exception handler
+// with the value describing the error, unwinding the call stack
+// and truncating the operand stack to the depth at the time of try.
+// It reports whether a handler was found.
+func (m *machine) catch(re *RuntimeError) bool {
+	cs := *m.Stack
+	i := len(cs) - 1
+	for ; i >= 0; i-- {
+		if len(cs[i].Handlers) > 0 {
+			break
+		}
+	}
+	if i < 0 {
+		return false
+	}
+
+	m.Stack.truncate(i + 1)
+	f := cs[i]
+	h := f.Handlers[len(f.Handlers)-1]
+	f.Handlers = f.Handlers[:len(f.Handlers)-1]
+
+	f.Operands.truncate(h.Depth)
+	f.Operands.Push(exceptionValue(re))
+	m.PC.SetIndex(h.Address)
+	return true
+}
+
+// exceptionValue returns the thrown value of the throw instruction,
+// or an object describing the error of any other instruction.
+func exceptionValue(re *RuntimeError) Value {
+	if te, ok := re.Err.(*ThrownError); ok {
+		return te.Value
+	}
+
+	return ObjectValue(map[string]Value{
+		"message":  StringValue(re.Err.Error()),
+		"mnemonic": StringValue(string(re.Mnemonic)),
+		"pc":       IntegerValue(re.PC),
+	})
+}
@@ -0,0 +1,169 @@
+package jsm
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestThrowCatch(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{StringValue("a")}},
+		{Mnemonic: MnemonicTry, Immediates: []Value{StringValue("catch")}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1), IntegerValue(2)}},
+		{Mnemonic: MnemonicThrow, Immediates: []Value{StringValue("oops")}},
+		{Label: "catch", Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(2)}},
+	}
+
+	m := NewMachine()
+	res, err := m.Run(p, nil)
+	assert.NoError(err)
+	assert.Equal([]Value{StringValue("a"), StringValue("oops")}, res)
+}
+
+func TestCatchRuntimeError(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicTry, Immediates: []Value{StringValue("catch")}},
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicCall, Immediates: []Value{StringValue("f"), IntegerValue(1)}},
+		{Mnemonic: MnemonicEndTry},
+		{Mnemonic: MnemonicPush, Immediates: []Value{StringValue("ok")}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(2)}},
+		{Label: "catch", Mnemonic: MnemonicPush, Immediates: []Value{StringValue("caught")}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(2)}},
+		{Label: "f", Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicDivide},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	}
+
+	m := NewMachine()
+	res, err := m.Run(p, []Value{IntegerValue(2)})
+	assert.NoError(err)
+	assert.Equal([]Value{NumberValue(0.5), StringValue("ok")}, res)
+
+	res, err = m.Run(p, []Value{IntegerValue(0)})
+	assert.NoError(err)
+	assert.Equal([]Value{
+		ObjectValue(map[string]Value{
+			"message":  StringValue("divide by zero"),
+			"mnemonic": StringValue(MnemonicDivide),
+			"pc":       IntegerValue(10),
+		}),
+		StringValue("caught"),
+	}, res)
+}
+
+func TestUncaughtException(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicTry, Immediates: []Value{StringValue("catch")}},
+		{Mnemonic: MnemonicEndTry},
+		{Mnemonic: MnemonicPush, Immediates: []Value{ArrayValue([]Value{IntegerValue(1)})}},
+		{Mnemonic: MnemonicThrow},
+		{Label: "catch", Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	}
+
+	m := NewMachine()
+	_, err := m.Run(p, nil)
+	assert.EqualError(err, "throw at 3: uncaught exception: [1]")
+
+	var te *ThrownError
+	assert.True(errors.As(err, &te))
+	assert.Equal(ArrayValue([]Value{IntegerValue(1)}), te.Value)
+
+	_, err = m.Run([]Instruction{{Mnemonic: MnemonicEndTry}}, nil)
+	assert.EqualError(err, "endtry at 0: no handler")
+}
+
+func TestVerifyTry(t *testing.T) {
+	assert := assert.New(t)
+
+	err := preprocessAndVerify([]Instruction{
+		{Mnemonic: MnemonicTry, Immediates: []Value{StringValue("catch")}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+		{Label: "catch", Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	})
+	assert.NoError(err)
+
+	err = preprocessAndVerify([]Instruction{
+		{Mnemonic: MnemonicTry, Immediates: []Value{StringValue("catch")}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1), IntegerValue(2)}},
+		{Label: "catch", Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	})
+	assert.EqualError(err, "inconsistent stack depth at 2: 1 and 2")
+
+	err = preprocessAndVerify([]Instruction{
+		{Mnemonic: MnemonicThrow},
+	})
+	assert.EqualError(err, "stack underflow at 0: throw needs 1 operands but has 0")
+}
+
+func TestCatchTruncatesOperands(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{StringValue("a"), StringValue("b")}},
+		{Mnemonic: MnemonicTry, Immediates: []Value{StringValue("catch")}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicDivide},
+		{Label: "catch", Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(3)}},
+	}
+
+	exc := ObjectValue(map[string]Value{
+		"message":  StringValue("divide by zero"),
+		"mnemonic": StringValue(MnemonicDivide),
+		"pc":       IntegerValue(4),
+	})
+
+	m := NewMachine()
+	res, err := m.Run(p, nil)
+	assert.NoError(err)
+	assert.Equal([]Value{StringValue("a"), StringValue("b"), exc}, res)
+
+	d := NewDebugger(m)
+	assert.NoError(d.Load(p, nil))
+	for i := 0; i < 3; i++ {
+		_, err := d.Step()
+		assert.NoError(err)
+	}
+
+	data, err := m.Dump()
+	assert.NoError(err)
+
+	m2 := newMachine()
+	assert.NoError(m2.Restore(data))
+	f, err := m2.Stack.Peek()
+	assert.NoError(err)
+	assert.Equal([]*handler{{Address: 5, Depth: 2}}, f.Handlers)
+
+	p = append([]Instruction{p[0], p[1], {Mnemonic: MnemonicPop, Immediates: []Value{IntegerValue(2)}}}, p[2:]...)
+	_, err = NewMachine().Run(p, nil)
+	assert.EqualError(err, "stack underflow at 2: pop pops operands saved by try")
+}
+
+func TestVerifyTryOperands(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := newPreprocessor().preprocess([]Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicTry, Immediates: []Value{StringValue("catch")}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(2)}},
+		{Mnemonic: MnemonicPop},
+		{Mnemonic: MnemonicEndTry},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		{Label: "catch", Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	})
+	assert.NoError(err)
+	assert.NoError(verify(p, nil, verifyStack))
+
+	p[3].Immediates = []Value{IntegerValue(2)}
+	assert.EqualError(verify(p, nil, verifyStack), "stack underflow at 3: pop pops operands saved by try")
+}
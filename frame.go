@@ -1,12 +1,50 @@
 package jsm
 
-import "github.com/pkg/errors"
+import (
+	"encoding/json"
+
+	"github.com/pkg/errors"
+)
 
 type frame struct {
-	Arguments []Value `json:"arguments"`
-	Locals    *heap   `json:"locals"`
-	Operands  *stack  `json:"operands"`
-	ReturnTo  int     `json:"returnTo"`
+	Arguments []Value    `json:"arguments"`
+	Locals    *heap      `json:"locals"`
+	Operands  *stack     `json:"operands"`
+	ReturnTo  int        `json:"returnTo"`
+	Handlers  []*handler `json:"handlers,omitempty"`
+	Env       Value      `json:"env,omitempty"`
+}
+
+// handler is an exception handler installed by the try instruction.
+// It keeps the depth of the operand stack at the time of the installation,
+// to which the operand stack is truncated when an exception is caught.
+// The operands below the depth are not restored if they have been popped,
+// which the verifier rejects.
+type handler struct {
+	Address int `json:"address"`
+	Depth   int `json:"depth"`
+}
+
+func (f *frame) MarshalJSON() ([]byte, error) {
+	type frameJSON frame
+	c := frameJSON(*f)
+	c.Arguments = escapeValues(c.Arguments)
+	c.Env = escapeValue(c.Env)
+	return json.Marshal(&c)
+}
+
+func (f *frame) UnmarshalJSON(data []byte) error {
+	type frameJSON frame
+	*f = frame{}
+	if err := json.Unmarshal(data, (*frameJSON)(f)); err != nil {
+		return err
+	}
+
+	for i, v := range f.Arguments {
+		f.Arguments[i] = restoreFunctions(v)
+	}
+	f.Env = restoreFunctions(f.Env)
+	return nil
 }
 
 func newFrame() *frame {
@@ -27,6 +65,47 @@ func (cs *callStack) Push(f *frame) {
 	*cs = append(*cs, f)
 }
 
+// pushFrame pushes an empty frame and returns it.
+// The frame left in the backing array by Pop is reused if any,
+// since the frames of returned calls are no longer referenced by the machine.
+// The local heaps and operand stacks retrieved by GetLocalHeap and GetOperandStack
+// are documented to be invalid after their functions return.
+// The local heap of the frame is counted by the limits of the frame below.
+func (cs *callStack) pushFrame() *frame {
+	l := len(*cs)
+	var lim *limits
+	if l > 0 {
+		lim = (*cs)[l-1].Locals.limits
+	}
+
+	if l == 0 || l == cap(*cs) {
+		f := newFrame()
+		f.Locals.attach(lim)
+		cs.Push(f)
+		return f
+	}
+
+	*cs = (*cs)[:l+1]
+	f := (*cs)[l]
+	if f == nil || f.Locals == nil || f.Operands == nil {
+		f = newFrame()
+		f.Locals.attach(lim)
+		(*cs)[l] = f
+		return f
+	}
+
+	f.Arguments = nil
+	if len(f.Locals.values) > 0 {
+		f.Locals.Clear()
+	}
+	f.Operands.Clear()
+	f.ReturnTo = 0
+	f.Handlers = nil
+	f.Env = nil
+	f.Locals.attach(lim)
+	return f
+}
+
 func (cs *callStack) Pop() (*frame, error) {
 	l := len(*cs)
 	if l == 0 {
@@ -34,6 +113,7 @@ func (cs *callStack) Pop() (*frame, error) {
 	}
 
 	f := (*cs)[l-1]
+	f.Locals.detach()
 	*cs = (*cs)[:l-1]
 	return f, nil
 }
@@ -47,5 +127,13 @@ func (cs *callStack) Peek() (*frame, error) {
 }
 
 func (cs *callStack) Clear() {
-	*cs = (*cs)[:0]
+	cs.truncate(0)
+}
+
+// truncate pops the frames above the given depth.
+func (cs *callStack) truncate(depth int) {
+	for _, f := range (*cs)[depth:] {
+		f.Locals.detach()
+	}
+	*cs = (*cs)[:depth]
 }
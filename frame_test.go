@@ -51,3 +51,43 @@ func TestCallStack(t *testing.T) {
 	_, err = cs.Pop()
 	assert.Error(err)
 }
+
+func TestCallStackReuse(t *testing.T) {
+	assert := assert.New(t)
+
+	cs := newCallStack()
+	cs.Push(newFrame())
+
+	f1 := cs.pushFrame()
+	f1.Arguments = []Value{IntegerValue(1)}
+	f1.Locals.Store("x", IntegerValue(2))
+	f1.Operands.Push(IntegerValue(3))
+	f1.ReturnTo = 4
+	f1.Handlers = []*handler{{Address: 5}}
+	f1.Env = ArrayValue([]Value{IntegerValue(6)})
+	_, err := cs.Pop()
+	assert.NoError(err)
+
+	f2 := cs.pushFrame()
+	assert.True(f1 == f2)
+	assert.Nil(f2.Arguments)
+	assert.Empty(f2.Locals.values)
+	assert.Empty(f2.Operands.slots)
+	assert.Equal(0, f2.ReturnTo)
+	assert.Nil(f2.Handlers)
+	assert.Nil(f2.Env)
+	assert.Len(*cs, 2)
+}
+
+func TestFrameHandlers(t *testing.T) {
+	assert := assert.New(t)
+
+	f := newFrame()
+	f.Handlers = []*handler{{Address: 3, Depth: 1}}
+
+	s := "{\"arguments\":null,\"locals\":{},\"operands\":[],\"returnTo\":0,\"handlers\":[{\"address\":3,\"depth\":1}]}"
+
+	j, err := json.Marshal(f)
+	assert.NoError(err)
+	assert.Equal(s, string(j))
+}
@@ -0,0 +1,222 @@
+package jsm
+
+import (
+	"context"
+	"encoding/json"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// Function is a reference to a function of JSM.
+// A function created by the closure instruction has an environment,
+// which is an array of captured operands or an object of captured local variables.
+type Function struct {
+	Address int   `json:"address"`
+	Env     Value `json:"env,omitempty"`
+}
+
+// FunctionValue returns the function value representing the specified function.
+func FunctionValue(f *Function) Value {
+	if f == nil {
+		return NullValue()
+	}
+	return f
+}
+
+// functionKey is the key of the object into which a function is marshaled.
+// The keys of the objects beginning with "$" are escaped with another "$" on marshaling,
+// so that no object other than a function is marshaled into an object with the only key functionKey.
+const functionKey = "$function"
+
+// MarshalJSON marshals a function into an object with the only key "$function",
+// so that it is restored as a function by Restore.
+func (f *Function) MarshalJSON() ([]byte, error) {
+	type function Function
+	c := function(*f)
+	c.Env = escapeValue(c.Env)
+	return json.Marshal(map[string]*function{functionKey: &c})
+}
+
+// escapeValue returns a value whose object keys beginning with "$" are escaped.
+// The value is copied only if it has such keys.
+func escapeValue(v Value) Value {
+	e, _ := escapeKeys(v)
+	return e
+}
+
+// escapeValues returns values whose object keys beginning with "$" are escaped.
+func escapeValues(vs []Value) []Value {
+	if e, ok := escapeKeys(vs); ok {
+		return e.([]Value)
+	}
+	return vs
+}
+
+func escapeKeys(v Value) (Value, bool) {
+	switch v := v.(type) {
+	case nil, bool, float64, string, *Function:
+		return v, false
+	case []Value:
+		var a []Value
+		for i, e := range v {
+			if e, ok := escapeKeys(e); ok {
+				if a == nil {
+					a = copyArray(v)
+				}
+				a[i] = e
+			}
+		}
+		if a == nil {
+			return v, false
+		}
+		return ArrayValue(a), true
+	case map[string]Value:
+		var o map[string]Value
+		for k, e := range v {
+			e, ok := escapeKeys(e)
+			escaped := strings.HasPrefix(k, "$")
+			if o == nil && (ok || escaped) {
+				o = make(map[string]Value, len(v))
+				for k, e := range v {
+					if !strings.HasPrefix(k, "$") {
+						o[k] = e
+					}
+				}
+			}
+			if o == nil {
+				continue
+			}
+			if escaped {
+				k = "$" + k
+			}
+			o[k] = e
+		}
+		if o == nil {
+			return v, false
+		}
+		return ObjectValue(o), true
+	}
+
+	if o, ok := objectOf(v); ok {
+		if e, ok := escapeKeys(o); ok {
+			return e, true
+		}
+	} else if a, ok := arrayOf(v); ok {
+		if e, ok := escapeKeys(a); ok {
+			return e, true
+		}
+	}
+	return v, false
+}
+
+// restoreFunctions converts the arrays and objects of a value decoded from JSON
+// into []Value and map[string]Value, and the marshaled functions into functions.
+// It also unescapes the keys escaped on marshaling.
+func restoreFunctions(v Value) Value {
+	switch v := v.(type) {
+	case []interface{}:
+		a := make([]Value, len(v))
+		for i, e := range v {
+			a[i] = restoreFunctions(e)
+		}
+		return ArrayValue(a)
+	case map[string]interface{}:
+		if f, ok := v[functionKey].(map[string]interface{}); ok && len(v) == 1 {
+			return FunctionValue(&Function{
+				Address: ToInteger(f["address"]),
+				Env:     restoreFunctions(f["env"]),
+			})
+		}
+
+		o := make(map[string]Value, len(v))
+		for k, e := range v {
+			if strings.HasPrefix(k, "$$") {
+				k = k[1:]
+			}
+			o[k] = restoreFunctions(e)
+		}
+		return ObjectValue(o)
+	default:
+		return v
+	}
+}
+
+// toFunction converts a function value or an address to a function.
+func toFunction(ctx context.Context, v Value) (*Function, error) {
+	f, ok := v.(*Function)
+	if !ok {
+		if TypeOf(v) != TypeNumber {
+			return nil, errors.New("not a function")
+		}
+		f = &Function{Address: ToInteger(v)}
+	}
+
+	if f == nil || f.Address < 0 || f.Address >= len(getProgram(ctx)) {
+		return nil, errors.New("invalid address")
+	}
+	return f, nil
+}
+
+func ldf(ctx context.Context, imms []Value) error {
+	addr, err := getAddress(imms, 0)
+	if err != nil {
+		return err
+	}
+
+	if err := doPush(ctx, FunctionValue(&Function{Address: addr})); err != nil {
+		return err
+	}
+
+	GetProgramCounter(ctx).Increment()
+	return nil
+}
+
+// closure creates a function that captures the given number of operands,
+// or a copy of the local variables if the number is omitted.
+func closure(ctx context.Context, imms []Value) error {
+	addr, err := getAddress(imms, 0)
+	if err != nil {
+		return err
+	}
+
+	var env Value
+	if len(imms) > 1 {
+		n, err := getCount(imms, 1, 0)
+		if err != nil {
+			return err
+		}
+
+		vs, err := doMultiPop(ctx, n)
+		if err != nil {
+			return err
+		}
+		env = ArrayValue(copyArray(vs))
+	} else {
+		frame, err := getFrame(ctx)
+		if err != nil {
+			return err
+		}
+		env = ObjectValue(frame.Locals.toObject())
+	}
+
+	if err := doPush(ctx, FunctionValue(&Function{Address: addr, Env: env})); err != nil {
+		return err
+	}
+
+	GetProgramCounter(ctx).Increment()
+	return nil
+}
+
+// ldc loads a value from the environment of the closure being called.
+func ldc(ctx context.Context, v Value) (Value, error) {
+	frame, err := getFrame(ctx)
+	if err != nil {
+		return NullValue(), err
+	}
+
+	if frame.Env == nil {
+		return NullValue(), errors.New("no environment")
+	}
+	return get([]Value{frame.Env, v})
+}
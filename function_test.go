@@ -0,0 +1,121 @@
+package jsm
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFunctionValue(t *testing.T) {
+	assert := assert.New(t)
+
+	f := &Function{Address: 1, Env: ArrayValue([]Value{IntegerValue(2)})}
+	assert.Equal(TypeFunction, TypeOf(FunctionValue(f)))
+	assert.Equal(TypeNull, TypeOf(FunctionValue(nil)))
+	assert.True(ToBoolean(FunctionValue(f)))
+	assert.Equal(`{"$function":{"address":1,"env":[2]}}`, ToString(FunctionValue(f)))
+	assert.True(Equal(FunctionValue(f), FunctionValue(&Function{Address: 1, Env: ArrayValue([]Value{IntegerValue(2)})})))
+	assert.False(Equal(FunctionValue(f), FunctionValue(&Function{Address: 1})))
+}
+
+func TestRestoreFunctions(t *testing.T) {
+	assert := assert.New(t)
+
+	f := &Function{Address: 1, Env: ObjectValue(map[string]Value{"g": FunctionValue(&Function{Address: 2})})}
+	h := newHeap()
+	h.Store("fs", ArrayValue([]Value{FunctionValue(f), ObjectValue(map[string]Value{"address": IntegerValue(3)})}))
+
+	data, err := h.Dump()
+	assert.NoError(err)
+
+	h2 := newHeap()
+	assert.NoError(h2.Restore(data))
+	v, err := h2.Load("fs")
+	assert.NoError(err)
+	assert.True(Equal(h.values["fs"].value(), v))
+	assert.Equal(TypeFunction, TypeOf(v.([]Value)[0]))
+	assert.Equal(TypeObject, TypeOf(v.([]Value)[1]))
+}
+
+func TestRestoreEscapedKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	o := ObjectValue(map[string]Value{functionKey: ObjectValue(map[string]Value{"address": IntegerValue(1)})})
+	p := ObjectValue(map[string]Value{"$": o, "$$x": IntegerValue(2), "y": ArrayValue([]Value{o})})
+	g := FunctionValue(&Function{Address: 3, Env: p})
+
+	f := newFrame()
+	f.Arguments = []Value{o}
+	f.Locals.Store("$function", p)
+	f.Operands.Push(g)
+	f.Env = o
+
+	data, err := json.Marshal(f)
+	assert.NoError(err)
+
+	var f2 frame
+	assert.NoError(json.Unmarshal(data, &f2))
+	assert.Equal(TypeObject, TypeOf(f2.Arguments[0]))
+	assert.True(Equal(f.Arguments[0], f2.Arguments[0]))
+	assert.True(Equal(f.Locals.values["$function"].value(), f2.Locals.values["$function"].value()))
+	assert.Equal(TypeFunction, TypeOf(f2.Operands.slots[0].value()))
+	assert.True(Equal(p, f2.Operands.slots[0].value().(*Function).Env))
+	assert.True(Equal(o, f2.Env))
+}
+
+func TestClosure(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{StringValue("f")}},
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicClosure, Immediates: []Value{StringValue("add"), IntegerValue(1)}},
+		{Mnemonic: MnemonicStoreLocal},
+		{Mnemonic: MnemonicStoreLocal, Immediates: []Value{StringValue("n"), IntegerValue(10)}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(3)}},
+		{Mnemonic: MnemonicClosure, Immediates: []Value{StringValue("mul")}},
+		{Mnemonic: MnemonicStoreLocal, Immediates: []Value{StringValue("n"), IntegerValue(20)}},
+		{Mnemonic: MnemonicCallValue, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(5)}},
+		{Mnemonic: MnemonicLoadLocal, Immediates: []Value{StringValue("f")}},
+		{Mnemonic: MnemonicCallValue, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicLoadLocal, Immediates: []Value{StringValue("f")}},
+		{Mnemonic: MnemonicTypeOf},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(3)}},
+		{Label: "add", Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicLoadCaptured, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicAdd},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		{Label: "mul", Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicLoadCaptured, Immediates: []Value{StringValue("n")}},
+		{Mnemonic: MnemonicMultiply},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	}
+
+	m := NewMachine()
+	res, err := m.Run(p, []Value{IntegerValue(2)})
+	assert.NoError(err)
+	assert.Equal([]Value{NumberValue(30.0), NumberValue(7.0), StringValue("function")}, res)
+
+	d := NewDebugger(m)
+	assert.NoError(d.Load(p, []Value{IntegerValue(2)}))
+	for i := 0; i < 8; i++ {
+		_, err := d.Step()
+		assert.NoError(err)
+	}
+
+	data, err := m.Dump()
+	assert.NoError(err)
+
+	m2 := newMachine()
+	assert.NoError(m2.Restore(data))
+	for m2.inProgress() {
+		assert.NoError(m2.step())
+	}
+	assert.Equal([]Value{NumberValue(30.0), NumberValue(7.0), StringValue("function")}, getResult(m2.context))
+
+	p[10] = Instruction{Mnemonic: MnemonicLoadFunction, Immediates: []Value{StringValue("add")}}
+	_, err = m.Run(p, []Value{IntegerValue(2)})
+	assert.EqualError(err, "ldc at 16 (add): no environment")
+}
@@ -0,0 +1,50 @@
+package jsm
+
+const defaultGasCost = 1
+
+type gasMeter struct {
+	limit    int
+	costs    map[Mnemonic]int
+	table    []int
+	consumed int
+}
+
+func newGasMeter() *gasMeter {
+	return &gasMeter{
+		costs: map[Mnemonic]int{},
+	}
+}
+
+func (g *gasMeter) cost(mnemonic Mnemonic) int {
+	if cost, ok := g.costs[mnemonic]; ok {
+		return cost
+	}
+	return defaultGasCost
+}
+
+func (g *gasMeter) load(program []Instruction) {
+	g.table = make([]int, len(program))
+	for idx, inst := range program {
+		g.table[idx] = g.cost(inst.Mnemonic)
+	}
+	g.consumed = 0
+}
+
+func (g *gasMeter) consume(idx int, inst *Instruction) error {
+	var c int
+	if idx < len(g.table) {
+		c = g.consumed + g.table[idx]
+	} else {
+		c = g.consumed + g.cost(inst.Mnemonic)
+	}
+	if g.limit > 0 && c > g.limit {
+		return &OutOfGasError{
+			PC:       idx,
+			Limit:    g.limit,
+			Consumed: g.consumed,
+		}
+	}
+
+	g.consumed = c
+	return nil
+}
@@ -0,0 +1,39 @@
+package jsm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGasMeter(t *testing.T) {
+	assert := assert.New(t)
+
+	g := newGasMeter()
+	g.limit = 5
+	g.costs[MnemonicAdd] = 3
+	g.costs["fib"] = 0
+
+	p := []Instruction{
+		{Mnemonic: MnemonicPush},
+		{Mnemonic: MnemonicAdd},
+		{Mnemonic: "fib"},
+	}
+	g.load(p)
+	assert.Equal([]int{1, 3, 0}, g.table)
+
+	assert.NoError(g.consume(0, &p[0]))
+	assert.NoError(g.consume(1, &p[1]))
+	assert.NoError(g.consume(2, &p[2]))
+	assert.Equal(4, g.consumed)
+
+	err := g.consume(1, &p[1])
+	assert.Equal(&OutOfGasError{PC: 1, Limit: 5, Consumed: 4}, err)
+	assert.Equal(4, g.consumed)
+
+	assert.NoError(g.consume(0, &p[0]))
+	assert.Equal(5, g.consumed)
+
+	g.load(p)
+	assert.Equal(0, g.consumed)
+}
@@ -0,0 +1,621 @@
+// Package gen generates Go source code from JSM programs.
+//
+// A generated function runs a program like Machine.Run of a machine created by jsm.NewMachine,
+// with the same operand stacks, arguments, local and global heaps, calls and jumps,
+// but without interpreting the instructions at run time.
+// It returns the same results and the same errors for the supported instructions:
+//
+//	nop push pop ld lda ldl st stl call ret jmp jt jf
+//	eq ne gt ge lt le not and or neg add sub mul div inc incl dec decl concat
+//
+// Gas, limits, hooks and exceptions are not supported.
+package gen
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go/format"
+	"go/token"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+
+	"github.com/pkg/errors"
+	"github.com/plenluno/jsm"
+)
+
+// Config configures the generated code.
+type Config struct {
+	// Package is the name of the package of the generated code.
+	Package string
+
+	// Func is the name of the generated function.
+	Func string
+}
+
+func (cfg Config) validate() error {
+	if !token.IsIdentifier(cfg.Package) {
+		return errors.Errorf("invalid package name: %q", cfg.Package)
+	}
+
+	if !token.IsIdentifier(cfg.Func) {
+		return errors.Errorf("invalid function name: %q", cfg.Func)
+	}
+	return nil
+}
+
+// Generate writes a Go source file that defines a function running the program.
+// The function has the following signature:
+//
+//	func(args []jsm.Value) (jsm.Value, error)
+func Generate(w io.Writer, program []jsm.Instruction, cfg Config) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	p, err := jsm.Prepare(program)
+	if err != nil {
+		return err
+	}
+
+	g := &generator{source: program, program: p}
+	if err := g.function(cfg.Func); err != nil {
+		return err
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by jsm gen. DO NOT EDIT.\n\npackage %s\n\n", cfg.Package)
+	fmt.Fprint(&buf, "import (\n")
+	if g.failing {
+		fmt.Fprint(&buf, "\t\"errors\"\n\n")
+	}
+	fmt.Fprint(&buf, "\t\"github.com/plenluno/jsm\"\n)\n\n")
+	buf.Write(g.buf.Bytes())
+	return write(w, buf.Bytes())
+}
+
+// GenerateTest writes a Go test file that checks that the function written by Generate
+// returns the same results and errors as Machine.Run for each of the argument lists.
+func GenerateTest(w io.Writer, program []jsm.Instruction, cfg Config, argss [][]jsm.Value) error {
+	if err := cfg.validate(); err != nil {
+		return err
+	}
+
+	p, err := json.Marshal(program)
+	if err != nil {
+		return errors.Wrap(err, "cannot convert program to json")
+	}
+
+	as, err := json.Marshal(argss)
+	if err != nil {
+		return errors.Wrap(err, "cannot convert arguments to json")
+	}
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "// Code generated by jsm gen. DO NOT EDIT.\n\npackage %s\n\n", cfg.Package)
+	fmt.Fprintf(&buf, `import (
+	"encoding/json"
+	"fmt"
+	"testing"
+
+	"github.com/plenluno/jsm"
+)
+
+func Test%s(t *testing.T) {
+	var program []jsm.Instruction
+	if err := json.Unmarshal([]byte(%s), &program); err != nil {
+		t.Fatal(err)
+	}
+
+	var argss [][]jsm.Value
+	if err := json.Unmarshal([]byte(%s), &argss); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, args := range argss {
+		want, wantErr := jsm.NewMachine().Run(program, args)
+		got, gotErr := %s(args)
+		if fmt.Sprint(gotErr) != fmt.Sprint(wantErr) {
+			t.Errorf("%%v: got error %%v, want %%v", args, gotErr, wantErr)
+		}
+		if !jsm.Equal(got, want) {
+			t.Errorf("%%v: got %%v, want %%v", args, got, want)
+		}
+	}
+}
+`, exported(cfg.Func), strconv.Quote(string(p)), strconv.Quote(string(as)), cfg.Func)
+	return write(w, buf.Bytes())
+}
+
+func exported(name string) string {
+	if name[0] >= 'a' && name[0] <= 'z' {
+		return string(name[0]-'a'+'A') + name[1:]
+	}
+	return name
+}
+
+func write(w io.Writer, src []byte) error {
+	src, err := format.Source(src)
+	if err != nil {
+		return errors.Wrap(err, "cannot format generated code")
+	}
+
+	_, err = w.Write(src)
+	return errors.Wrap(err, "cannot write generated code")
+}
+
+type generator struct {
+	source  []jsm.Instruction
+	program []jsm.Instruction
+	buf     bytes.Buffer
+
+	// failing, stack and heap tell whether the generated code uses fail, stack and heap.
+	failing bool
+	stack   bool
+	heap    bool
+}
+
+func (g *generator) printf(format string, args ...interface{}) {
+	fmt.Fprintf(&g.buf, format, args...)
+}
+
+func (g *generator) function(name string) error {
+	var body generator
+	body.source = g.source
+	body.program = g.program
+	for idx := range g.program {
+		if err := body.instruction(idx); err != nil {
+			return err
+		}
+	}
+	g.failing = body.failing
+	g.stack = body.stack || body.failing
+
+	n := len(g.program)
+	g.printf("// %s runs a JSM program like Machine.Run of a machine created by jsm.NewMachine.\n", name)
+	g.printf("func %s(args []jsm.Value) (jsm.Value, error) {\n", name)
+	if g.stack {
+		g.printf(`type frame struct {
+			args     []jsm.Value
+			locals   map[string]jsm.Value
+			operands []jsm.Value
+			returnTo int
+		}
+
+		if args == nil {
+			args = []jsm.Value{}
+		}
+
+		stack := []*frame{{args: args, locals: map[string]jsm.Value{}, returnTo: %d}}
+		`, n)
+	}
+	if body.heap {
+		g.printf("heap := map[string]jsm.Value{}\n")
+	}
+	g.printf("var result jsm.Value\npc := 0\n\n")
+	if body.failing {
+		g.fail()
+	}
+	g.printf("for pc >= 0 && pc < %d {\nswitch pc {\n", n)
+	g.buf.Write(body.buf.Bytes())
+	g.printf("}\n}\nreturn result, nil\n}\n")
+	return nil
+}
+
+// fail writes a closure that creates a runtime error at the current instruction.
+func (g *generator) fail() {
+	mnemonics := make([]string, len(g.program))
+	labels := make([]string, len(g.program))
+	comments := make([]string, len(g.program))
+	label := ""
+	for idx, inst := range g.source {
+		if inst.Label != "" {
+			label = inst.Label
+		}
+		mnemonics[idx] = strconv.Quote(string(inst.Mnemonic))
+		labels[idx] = strconv.Quote(label)
+		comments[idx] = strconv.Quote(inst.Comment)
+	}
+
+	g.printf("fail := func(msg string) error {\n")
+	g.printf("mnemonics := [...]jsm.Mnemonic{%s}\n", join(mnemonics))
+	g.printf("labels := [...]string{%s}\n", join(labels))
+	g.printf("comments := [...]string{%s}\n", join(comments))
+	g.printf(`re := &jsm.RuntimeError{
+			Err:      errors.New(msg),
+			PC:       pc,
+			Mnemonic: mnemonics[pc],
+			Label:    labels[pc],
+			Comment:  comments[pc],
+		}
+		re.StackTrace = append(re.StackTrace, jsm.Location{PC: pc, Label: labels[pc]})
+		for i := len(stack) - 1; i > 0; i-- {
+			ret := stack[i].returnTo - 1
+			re.StackTrace = append(re.StackTrace, jsm.Location{PC: ret, Label: labels[ret]})
+		}
+		return re
+	}
+
+	`)
+}
+
+func join(ss []string) string {
+	var buf bytes.Buffer
+	for i, s := range ss {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+		buf.WriteString(s)
+	}
+	return buf.String()
+}
+
+// operations are the expressions of the operations on a and b.
+var operations = map[jsm.Mnemonic]string{
+	jsm.MnemonicEqual:          "jsm.BooleanValue(jsm.Equal(a, b))",
+	jsm.MnemonicNotEqual:       "jsm.BooleanValue(!jsm.Equal(a, b))",
+	jsm.MnemonicGreaterThan:    "jsm.BooleanValue(jsm.Less(b, a))",
+	jsm.MnemonicGreaterOrEqual: "jsm.BooleanValue(jsm.Less(b, a) || jsm.Equal(b, a))",
+	jsm.MnemonicLessThan:       "jsm.BooleanValue(jsm.Less(a, b))",
+	jsm.MnemonicLessOrEqual:    "jsm.BooleanValue(jsm.Less(a, b) || jsm.Equal(a, b))",
+	jsm.MnemonicAnd:            "jsm.BooleanValue(jsm.ToBoolean(a) && jsm.ToBoolean(b))",
+	jsm.MnemonicOr:             "jsm.BooleanValue(jsm.ToBoolean(a) || jsm.ToBoolean(b))",
+	jsm.MnemonicAdd:            "jsm.NumberValue(jsm.ToNumber(a) + jsm.ToNumber(b))",
+	jsm.MnemonicSubtract:       "jsm.NumberValue(jsm.ToNumber(a) - jsm.ToNumber(b))",
+	jsm.MnemonicMultiply:       "jsm.NumberValue(jsm.ToNumber(a) * jsm.ToNumber(b))",
+	jsm.MnemonicDivide:         "jsm.NumberValue(jsm.ToNumber(a) / jsm.ToNumber(b))",
+	jsm.MnemonicConcat:         "jsm.StringValue(jsm.ToString(a) + jsm.ToString(b))",
+	jsm.MnemonicNot:            "jsm.BooleanValue(!jsm.ToBoolean(a))",
+	jsm.MnemonicNeg:            "jsm.NumberValue(-jsm.ToNumber(a))",
+}
+
+func (g *generator) instruction(idx int) error {
+	inst := &g.program[idx]
+	imms := inst.Immediates
+	g.printf("case %d: // %s\n", idx, inst.Mnemonic)
+
+	switch m := inst.Mnemonic; m {
+	case jsm.MnemonicNop:
+	case jsm.MnemonicPush:
+		if len(imms) > 0 {
+			vs, err := g.literals(idx, imms)
+			if err != nil {
+				return err
+			}
+			g.frame()
+			g.printf("f.operands = append(f.operands, %s)\n", vs)
+		}
+	case jsm.MnemonicPop:
+		n := 1
+		if len(imms) > 0 {
+			n = jsm.ToInteger(imms[0])
+		}
+		if n < 1 {
+			g.failWith("invalid count")
+			return nil
+		}
+		g.frame()
+		g.popN("_", n)
+	case jsm.MnemonicLoad, jsm.MnemonicLoadArgument, jsm.MnemonicLoadLocal:
+		v, err := g.operand(idx, imms, true)
+		if err != nil {
+			return err
+		}
+		switch m {
+		case jsm.MnemonicLoad:
+			g.heap = true
+			g.printf("f.operands = append(f.operands, heap[jsm.ToString(%s)])\n", v)
+		case jsm.MnemonicLoadArgument:
+			if len(imms) > 0 {
+				v = strconv.Itoa(jsm.ToInteger(imms[0]))
+			} else {
+				v = "jsm.ToInteger(v)"
+			}
+			g.printf(`i := %s
+			if i >= len(f.args) {
+				return nil, fail("argument out of range")
+			}
+			f.operands = append(f.operands, f.args[i])
+			`, v)
+			g.failing = true
+		default:
+			g.printf("f.operands = append(f.operands, f.locals[jsm.ToString(%s)])\n", v)
+		}
+	case jsm.MnemonicStore, jsm.MnemonicStoreLocal:
+		if len(imms) < 2 || m == jsm.MnemonicStoreLocal {
+			g.frame()
+		}
+		var k, v string
+		switch len(imms) {
+		case 0:
+			g.popN("vs", 2)
+			k, v = "vs[0]", "vs[1]"
+		case 1:
+			g.popN("vs", 1)
+			k, v = "vs[0]", g.mustLiteral(imms[0])
+		default:
+			lit, err := g.literal(idx, imms[1])
+			if err != nil {
+				return err
+			}
+			k, v = g.mustLiteral(imms[0]), lit
+		}
+		if m == jsm.MnemonicStore {
+			g.heap = true
+			g.printf("heap[jsm.ToString(%s)] = %s\n", k, v)
+		} else {
+			g.printf("f.locals[jsm.ToString(%s)] = %s\n", k, v)
+		}
+	case jsm.MnemonicIncrement, jsm.MnemonicIncrementLocal, jsm.MnemonicDecrement, jsm.MnemonicDecrementLocal:
+		local := m == jsm.MnemonicIncrementLocal || m == jsm.MnemonicDecrementLocal
+		v, err := g.operand(idx, imms, local)
+		if err != nil {
+			return err
+		}
+		h := "f.locals"
+		if !local {
+			g.heap = true
+			h = "heap"
+		}
+		op := "+"
+		if m == jsm.MnemonicDecrement || m == jsm.MnemonicDecrementLocal {
+			op = "-"
+		}
+		g.printf("k := jsm.ToString(%s)\n%s[k] = jsm.NumberValue(jsm.ToNumber(%s[k]) %s 1)\n", v, h, h, op)
+	case jsm.MnemonicCall:
+		addr := jsm.ToInteger(imms[0])
+		argc := 0
+		if len(imms) > 1 {
+			argc = jsm.ToInteger(imms[1])
+		}
+		if addr < 0 {
+			g.failWith("invalid address")
+			return nil
+		}
+		if argc < 0 {
+			g.failWith("invalid count")
+			return nil
+		}
+		if argc > 0 {
+			g.frame()
+			g.popN("argv", argc)
+		} else {
+			g.stack = true
+			g.printf("argv := []jsm.Value{}\n")
+		}
+		g.printf("stack = append(stack, &frame{args: argv, locals: map[string]jsm.Value{}, returnTo: %d})\n", idx+1)
+		g.printf("pc = %d\ncontinue\n", addr)
+		return nil
+	case jsm.MnemonicReturn:
+		n := 0
+		if len(imms) > 0 {
+			n = jsm.ToInteger(imms[0])
+		}
+		if n < 0 {
+			g.failWith("invalid count")
+			return nil
+		}
+		g.frame()
+		g.popN("res", n)
+		if n == 0 {
+			g.printf("res := []jsm.Value{}\n")
+		}
+		g.printf(`pc = f.returnTo
+		stack = stack[:len(stack)-1]
+		if len(stack) > 0 {
+			stack[len(stack)-1].operands = append(stack[len(stack)-1].operands, res...)
+		} else {
+			result = jsm.ArrayValue(res)
+		}
+		continue
+		`)
+		return nil
+	case jsm.MnemonicJump, jsm.MnemonicJumpIfTrue, jsm.MnemonicJumpIfFalse:
+		addr := jsm.ToInteger(imms[0])
+		if addr < 0 {
+			g.failWith("invalid address")
+			return nil
+		}
+		if m == jsm.MnemonicJump {
+			g.printf("pc = %d\ncontinue\n", addr)
+			return nil
+		}
+
+		g.frame()
+		g.pop("v")
+		cond := "jsm.ToBoolean(v)"
+		if m == jsm.MnemonicJumpIfFalse {
+			cond = "!" + cond
+		}
+		g.printf("if %s {\npc = %d\ncontinue\n}\n", cond, addr)
+	case jsm.MnemonicNot, jsm.MnemonicNeg:
+		g.frame()
+		g.operands(1)
+		g.printf("a := f.operands[n-1]\nf.operands[n-1] = %s\n", operations[m])
+	default:
+		expr, ok := operations[m]
+		if !ok {
+			return errors.Errorf("unsupported instruction at %d: %s", idx, m)
+		}
+
+		g.frame()
+		if len(imms) > 0 {
+			lit, err := g.literal(idx, imms[0])
+			if err != nil {
+				return err
+			}
+			g.operands(1)
+			g.printf("a, b := f.operands[n-1], jsm.Value(%s)\n", lit)
+		} else {
+			g.operands(2)
+			g.printf("a, b := f.operands[n-2], f.operands[n-1]\nf.operands = f.operands[:n-1]\n")
+		}
+		if m == jsm.MnemonicDivide {
+			g.printf("if jsm.ToNumber(b) == 0 {\nreturn nil, fail(\"divide by zero\")\n}\n")
+		}
+		g.printf("f.operands[len(f.operands)-1] = %s\n", expr)
+	}
+
+	g.printf("pc++\n")
+	return nil
+}
+
+// frame writes the code that gets the current frame.
+func (g *generator) frame() {
+	g.stack = true
+	g.printf("f := stack[len(stack)-1]\n")
+}
+
+// operand writes the code that takes the operand of an instruction
+// from its immediate or the operand stack, and returns the expression of the operand.
+// The current frame is got if the operand is popped or the instruction needs it.
+func (g *generator) operand(idx int, imms []jsm.Value, frame bool) (string, error) {
+	if len(imms) > 0 {
+		if frame {
+			g.frame()
+		}
+		return g.literal(idx, imms[0])
+	}
+
+	g.frame()
+	g.pop("v")
+	return "v", nil
+}
+
+func (g *generator) pop(name string) {
+	g.failing = true
+	g.printf(`if len(f.operands) == 0 {
+		return nil, fail("no operand")
+	}
+	%s := f.operands[len(f.operands)-1]
+	f.operands = f.operands[:len(f.operands)-1]
+	`, name)
+}
+
+// popN writes the code that pops n operands into a new slice.
+func (g *generator) popN(name string, n int) {
+	if n == 0 {
+		return
+	}
+
+	g.failing = true
+	g.printf(`if len(f.operands) < %d {
+		return nil, fail("too few operands")
+	}
+	`, n)
+	if name != "_" {
+		g.printf("%s := append([]jsm.Value{}, f.operands[len(f.operands)-%d:]...)\n", name, n)
+	}
+	g.printf("f.operands = f.operands[:len(f.operands)-%d]\n", n)
+}
+
+// operands writes the code that checks that the operand stack has at least k operands
+// and stores its length in n.
+func (g *generator) operands(k int) {
+	g.failing = true
+	g.printf(`n := len(f.operands)
+	if n < %d {
+		return nil, fail("too few operands")
+	}
+	`, k)
+}
+
+func (g *generator) failWith(msg string) {
+	g.failing = true
+	g.printf("return nil, fail(%q)\n", msg)
+}
+
+func (g *generator) literals(idx int, vs []jsm.Value) (string, error) {
+	ls := make([]string, len(vs))
+	for i, v := range vs {
+		l, err := g.literal(idx, v)
+		if err != nil {
+			return "", err
+		}
+		ls[i] = l
+	}
+	return join(ls), nil
+}
+
+func (g *generator) literal(idx int, v jsm.Value) (string, error) {
+	l, ok := literal(v)
+	if !ok {
+		return "", errors.Errorf("unsupported immediate at %d: %v", idx, v)
+	}
+	return l, nil
+}
+
+// mustLiteral returns the literal of a value normalized by the preprocessor.
+func (g *generator) mustLiteral(v jsm.Value) string {
+	l, _ := literal(v)
+	return l
+}
+
+// literal returns a Go expression that evaluates to a value of the same type.
+func literal(v jsm.Value) (string, bool) {
+	switch v := v.(type) {
+	case nil:
+		return "nil", true
+	case bool:
+		return fmt.Sprintf("jsm.BooleanValue(%t)", v), true
+	case int:
+		return fmt.Sprintf("jsm.IntegerValue(%d)", v), true
+	case float64:
+		if math.IsNaN(v) || math.IsInf(v, 0) || (v == 0 && math.Signbit(v)) {
+			return "", false
+		}
+		return fmt.Sprintf("jsm.NumberValue(%s)", strconv.FormatFloat(v, 'g', -1, 64)), true
+	case string:
+		return fmt.Sprintf("jsm.StringValue(%s)", strconv.Quote(v)), true
+	case []interface{}:
+		return elements("[]interface{}", v)
+	case []jsm.Value:
+		vs := make([]interface{}, len(v))
+		for i, e := range v {
+			vs[i] = e
+		}
+		return elements("[]jsm.Value", vs)
+	case map[string]interface{}:
+		return fields("map[string]interface{}", v)
+	case map[string]jsm.Value:
+		vs := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			vs[k] = e
+		}
+		return fields("map[string]jsm.Value", vs)
+	default:
+		return "", false
+	}
+}
+
+func elements(typ string, vs []interface{}) (string, bool) {
+	ls := make([]string, len(vs))
+	for i, v := range vs {
+		l, ok := literal(v)
+		if !ok {
+			return "", false
+		}
+		ls[i] = l
+	}
+	return typ + "{" + join(ls) + "}", true
+}
+
+func fields(typ string, vs map[string]interface{}) (string, bool) {
+	ks := make([]string, 0, len(vs))
+	for k := range vs {
+		ks = append(ks, k)
+	}
+	sort.Strings(ks)
+
+	ls := make([]string, len(ks))
+	for i, k := range ks {
+		l, ok := literal(vs[k])
+		if !ok {
+			return "", false
+		}
+		ls[i] = strconv.Quote(k) + ": " + l
+	}
+	return typ + "{" + join(ls) + "}", true
+}
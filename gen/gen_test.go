@@ -0,0 +1,116 @@
+package gen
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/plenluno/jsm"
+	"github.com/stretchr/testify/assert"
+)
+
+var update = flag.Bool("update", false, "update the generated examples")
+
+// examples are the programs generated into the package internal/examples,
+// whose generated tests verify that they behave like Machine.Run.
+var examples = []struct {
+	path string
+	name string
+	fn   string
+	args string
+}{
+	{"../examples/fibonacci.json", "fibonacci", "Fibonacci", `[[10], [1], []]`},
+	{"../examples/sum_of_series.json", "sum_of_series", "SumOfSeries", `[[100], [0], [], ["x"]]`},
+	{"testdata/operations.json", "operations", "Operations", `[[2], [-3], [0], [], ["x"]]`},
+}
+
+func loadProgram(t *testing.T, path string) []jsm.Instruction {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var p []jsm.Instruction
+	if err := json.Unmarshal(data, &p); err != nil {
+		t.Fatal(err)
+	}
+	return p
+}
+
+func TestGenerate(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, e := range examples {
+		p := loadProgram(t, e.path)
+		cfg := Config{Package: "examples", Func: e.fn}
+
+		var argss [][]jsm.Value
+		assert.NoError(json.Unmarshal([]byte(e.args), &argss))
+
+		var src, test bytes.Buffer
+		assert.NoError(Generate(&src, p, cfg))
+		assert.NoError(GenerateTest(&test, p, cfg, argss))
+
+		files := map[string][]byte{
+			filepath.Join("internal", "examples", e.name+".go"):      src.Bytes(),
+			filepath.Join("internal", "examples", e.name+"_test.go"): test.Bytes(),
+		}
+		for path, data := range files {
+			if *update {
+				assert.NoError(ioutil.WriteFile(path, data, 0644))
+				continue
+			}
+
+			expected, err := ioutil.ReadFile(path)
+			if os.IsNotExist(err) {
+				t.Fatalf("%s does not exist; run go test -update", path)
+			}
+			assert.NoError(err)
+			assert.Equal(string(expected), string(data), path)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	p := []jsm.Instruction{{Mnemonic: jsm.MnemonicReturn}}
+	assert.EqualError(Generate(&buf, p, Config{Package: "main", Func: "1st"}), `invalid function name: "1st"`)
+	assert.EqualError(Generate(&buf, p, Config{Func: "Run"}), `invalid package name: ""`)
+
+	p = []jsm.Instruction{
+		{Mnemonic: jsm.MnemonicPush, Immediates: []jsm.Value{jsm.StringValue("x")}},
+		{Mnemonic: jsm.MnemonicTypeOf},
+	}
+	assert.EqualError(Generate(&buf, p, Config{Package: "main", Func: "Run"}), "unsupported instruction at 1: typeof")
+
+	p = []jsm.Instruction{{Mnemonic: jsm.MnemonicPush, Immediates: []jsm.Value{math.Inf(1)}}}
+	assert.EqualError(Generate(&buf, p, Config{Package: "main", Func: "Run"}), "unsupported immediate at 0: +Inf")
+
+	p = []jsm.Instruction{{Mnemonic: jsm.MnemonicPush, Immediates: []jsm.Value{int64(1)}}}
+	assert.EqualError(Generate(&buf, p, Config{Package: "main", Func: "Run"}), "unsupported immediate at 0: 1")
+
+	p = []jsm.Instruction{{Mnemonic: jsm.MnemonicJump, Immediates: []jsm.Value{jsm.StringValue("none")}}}
+	assert.Error(Generate(&buf, p, Config{Package: "main", Func: "Run"}))
+	assert.Equal(0, buf.Len())
+}
+
+func TestGenerateMinimal(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	p := []jsm.Instruction{
+		{Mnemonic: jsm.MnemonicJump, Immediates: []jsm.Value{jsm.StringValue("end")}},
+		{Label: "end", Mnemonic: jsm.MnemonicNop},
+	}
+	assert.NoError(Generate(&buf, p, Config{Package: "main", Func: "run"}))
+	assert.NotContains(buf.String(), "errors")
+	assert.NotContains(buf.String(), "stack")
+	assert.Contains(buf.String(), "func run(args []jsm.Value) (jsm.Value, error) {")
+}
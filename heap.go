@@ -2,6 +2,7 @@ package jsm
 
 import (
 	"encoding/json"
+	"reflect"
 
 	"github.com/pkg/errors"
 )
@@ -19,26 +20,124 @@ type Heap interface {
 	Store(k string, v Value)
 }
 
-type heap map[string]Value
+type heap struct {
+	values map[string]slot
+
+	// limits are the limits of the machine whose heap usage includes this heap, if any.
+	// The sizes of the entries are kept only if the limits count bytes,
+	// so that a store does not size the value it replaces.
+	limits *limits
+	sizes  map[string]int
+	size   int
+}
 
 func newHeap() *heap {
-	return &heap{}
+	return &heap{values: map[string]slot{}}
 }
 
 func (h *heap) Load(k string) (Value, error) {
-	v, ok := (*h)[k]
+	sl, ok := h.values[k]
 	if !ok {
 		return NullValue(), errors.New("not found")
 	}
-	return v, nil
+	return sl.value(), nil
 }
 
 func (h *heap) Store(k string, v Value) {
-	(*h)[k] = v
+	sl := toSlot(v)
+	h.put(k, sl, h.entrySize(k, sl))
+}
+
+// load returns the slot of the value associated with the specified key,
+// or the slot of null if the key has no associated value.
+func (h *heap) load(k string) slot {
+	return h.values[k]
+}
+
+// store is like Store, but fails without storing the value
+// if the heaps of the machine would exceed the limits.
+func (h *heap) store(k string, v Value) error {
+	return h.storeSlot(k, toSlot(v))
+}
+
+func (h *heap) storeSlot(k string, sl slot) error {
+	size := h.entrySize(k, sl)
+	if l := h.limits; l != nil {
+		keys := l.heapKeys
+		if _, ok := h.values[k]; !ok {
+			keys++
+		}
+		if err := l.checkHeap(keys, l.heapBytes+size-h.sizes[k]); err != nil {
+			return err
+		}
+	}
+
+	h.put(k, sl, size)
+	return nil
+}
+
+// entrySize returns the size of an entry if the limits count bytes, or zero otherwise.
+func (h *heap) entrySize(k string, sl slot) int {
+	if h.limits == nil || h.limits.maxHeapBytes <= 0 {
+		return 0
+	}
+	return keySize + len(k) + sizeOfSlot(sl)
+}
+
+func (h *heap) put(k string, sl slot, size int) {
+	if _, ok := h.values[k]; !ok && h.limits != nil {
+		h.limits.heapKeys++
+	}
+	h.values[k] = sl
+
+	if size > 0 {
+		if h.sizes == nil {
+			h.sizes = map[string]int{}
+		}
+		d := size - h.sizes[k]
+		h.sizes[k] = size
+		h.size += d
+		h.limits.heapBytes += d
+	}
+}
+
+// attach includes the heap in the heap usage counted by the limits.
+func (h *heap) attach(l *limits) {
+	h.limits = l
+	h.sizes = nil
+	h.size = 0
+	if l == nil {
+		return
+	}
+
+	l.heapKeys += len(h.values)
+	if l.maxHeapBytes > 0 {
+		h.sizes = make(map[string]int, len(h.values))
+		for k, sl := range h.values {
+			size := keySize + len(k) + sizeOfSlot(sl)
+			h.sizes[k] = size
+			h.size += size
+		}
+		l.heapBytes += h.size
+	}
+}
+
+// detach excludes the heap from the heap usage counted by its limits.
+func (h *heap) detach() {
+	if h.limits != nil {
+		h.limits.heapKeys -= len(h.values)
+		h.limits.heapBytes -= h.size
+	}
+	h.limits = nil
+	h.sizes = nil
+	h.size = 0
 }
 
 func (h *heap) Clear() {
-	*h = map[string]Value{}
+	l := h.limits
+	h.detach()
+	h.values = map[string]slot{}
+	h.limits = l
 }
 
 func (h *heap) Dump() ([]byte, error) {
@@ -49,3 +148,97 @@ func (h *heap) Dump() ([]byte, error) {
 func (h *heap) Restore(data []byte) error {
 	return errors.Wrap(json.Unmarshal(data, h), "failed to restore heap")
 }
+
+func (h *heap) MarshalJSON() ([]byte, error) {
+	vs := make(map[string]Value, len(h.values))
+	for k, sl := range h.values {
+		vs[k] = escapeValue(sl.value())
+	}
+	return json.Marshal(vs)
+}
+
+// toObject returns a copy of the values in the heap.
+func (h *heap) toObject() map[string]Value {
+	o := make(map[string]Value, len(h.values))
+	for k, sl := range h.values {
+		o[k] = sl.value()
+	}
+	return o
+}
+
+func (h *heap) UnmarshalJSON(data []byte) error {
+	l := h.limits
+	h.detach()
+	defer h.attach(l)
+
+	var vs map[string]Value
+	if err := json.Unmarshal(data, &vs); err != nil {
+		return err
+	}
+
+	if h.values == nil {
+		h.values = make(map[string]slot, len(vs))
+	}
+	for k, v := range vs {
+		h.values[k] = toSlot(restoreFunctions(v))
+	}
+	return nil
+}
+
+// These constants are the approximate sizes of values in bytes.
+const (
+	keySize    = 16
+	scalarSize = 8
+	stringSize = 16
+	arraySize  = 24
+	objectSize = 48
+)
+
+func sizeOfSlot(sl slot) int {
+	if _, ok := sl.number(); ok {
+		return scalarSize
+	}
+	return sizeOf(sl.v)
+}
+
+func sizeOf(v Value) int {
+	switch v := v.(type) {
+	case string:
+		return stringSize + len(v)
+	case []Value:
+		size := arraySize
+		for _, e := range v {
+			size += sizeOf(e)
+		}
+		return size
+	case map[string]Value:
+		size := objectSize
+		for k, e := range v {
+			size += keySize + len(k) + sizeOf(e)
+		}
+		return size
+	case *Function:
+		if v == nil {
+			return scalarSize
+		}
+		return scalarSize + sizeOf(v.Env)
+	}
+
+	val := reflect.ValueOf(v)
+	switch val.Kind() {
+	case reflect.Slice:
+		size := arraySize
+		for i := 0; i < val.Len(); i++ {
+			size += sizeOf(val.Index(i).Interface())
+		}
+		return size
+	case reflect.Map:
+		size := objectSize
+		for _, k := range val.MapKeys() {
+			size += keySize + len(ToString(k.Interface())) + sizeOf(val.MapIndex(k).Interface())
+		}
+		return size
+	default:
+		return scalarSize
+	}
+}
@@ -1,6 +1,7 @@
 package jsm
 
 import (
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -61,3 +62,42 @@ func TestHeapDumpRestore(t *testing.T) {
 	err = h2.Restore([]byte{})
 	assert.Error(err)
 }
+
+func TestHeapSize(t *testing.T) {
+	assert := assert.New(t)
+
+	l := &limits{maxHeapBytes: 1000}
+	h := newHeap()
+	h.attach(l)
+	h.Store("abc", IntegerValue(123))
+	assert.Equal(keySize+3+scalarSize, h.size)
+
+	h.Store("abc", StringValue("xyz"))
+	assert.Equal(keySize+3+stringSize+3, h.size)
+
+	h.Store("a", ArrayValue([]Value{NumberValue(1.0), ObjectValue(map[string]Value{"b": nil})}))
+	assert.Equal(keySize+3+stringSize+3+keySize+1+arraySize+scalarSize+objectSize+keySize+1+scalarSize, h.size)
+	assert.Equal(2, l.heapKeys)
+	assert.Equal(h.size, l.heapBytes)
+
+	d, err := h.Dump()
+	assert.NoError(err)
+
+	h2 := newHeap()
+	h2.attach(l)
+	assert.NoError(h2.Restore(d))
+	assert.Equal(h.size, h2.size)
+	assert.Equal(4, l.heapKeys)
+	assert.Equal(2*h.size, l.heapBytes)
+
+	h.Clear()
+	assert.Equal(0, h.size)
+	h2.detach()
+	assert.Equal(0, l.heapKeys)
+	assert.Equal(0, l.heapBytes)
+
+	h.Store("abc", IntegerValue(123))
+	assert.EqualError(h.store("def", StringValue(strings.Repeat("x", 1000))), "heap exhausted at 0: 2 keys, 1062 bytes")
+	assert.Equal(1, l.heapKeys)
+	assert.Equal(keySize+3+scalarSize, l.heapBytes)
+}
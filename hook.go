@@ -0,0 +1,71 @@
+package jsm
+
+import "context"
+
+// Hook observes the execution of programs on a Machine.
+// The context passed to its methods can be used to retrieve the state of the Machine,
+// but the local heaps and operand stacks retrieved must not be kept after the methods return.
+type Hook interface {
+	// BeforeInstruction is called before the instruction at pc is executed
+	// with a snapshot of the current operand stack.
+	BeforeInstruction(ctx context.Context, pc int, inst *Instruction, operands []Value)
+
+	// AfterInstruction is called after the instruction at pc is executed
+	// with a snapshot of the current operand stack and the error, if any.
+	AfterInstruction(ctx context.Context, pc int, inst *Instruction, operands []Value, err error)
+
+	// Call is called when the instruction at pc pushes a new frame onto the call stack
+	// and transfers control to the instruction at addr.
+	// depth is the depth of the call stack after the call.
+	Call(ctx context.Context, pc, addr, depth int)
+
+	// Return is called when the instruction at pc pops a frame from the call stack
+	// and transfers control to the instruction at addr.
+	// depth is the depth of the call stack after the return.
+	Return(ctx context.Context, pc, addr, depth int)
+}
+
+// NopHook is a Hook that does nothing.
+// It can be embedded in a struct to implement only some methods of Hook.
+type NopHook struct{}
+
+// BeforeInstruction does nothing.
+func (NopHook) BeforeInstruction(ctx context.Context, pc int, inst *Instruction, operands []Value) {}
+
+// AfterInstruction does nothing.
+func (NopHook) AfterInstruction(ctx context.Context, pc int, inst *Instruction, operands []Value, err error) {
+}
+
+// Call does nothing.
+func (NopHook) Call(ctx context.Context, pc, addr, depth int) {}
+
+// Return does nothing.
+func (NopHook) Return(ctx context.Context, pc, addr, depth int) {}
+
+type hooks []Hook
+
+func (hs hooks) before(ctx context.Context, pc int, inst *Instruction, operands []Value) {
+	for _, h := range hs {
+		h.BeforeInstruction(ctx, pc, inst, operands)
+	}
+}
+
+func (hs hooks) after(ctx context.Context, pc int, inst *Instruction, operands []Value, err error) {
+	for _, h := range hs {
+		h.AfterInstruction(ctx, pc, inst, operands, err)
+	}
+}
+
+func (hs hooks) transit(ctx context.Context, pc, from, to int) {
+	addr := GetProgramCounter(ctx).Index()
+	for depth := from + 1; depth <= to; depth++ {
+		for _, h := range hs {
+			h.Call(ctx, pc, addr, depth)
+		}
+	}
+	for depth := from - 1; depth >= to; depth-- {
+		for _, h := range hs {
+			h.Return(ctx, pc, addr, depth)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package jsm
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type recordingHook struct {
+	events []string
+}
+
+func (h *recordingHook) BeforeInstruction(ctx context.Context, pc int, inst *Instruction, operands []Value) {
+	h.events = append(h.events, fmt.Sprintf("before %d %s %v", pc, inst.Mnemonic, operands))
+}
+
+func (h *recordingHook) AfterInstruction(ctx context.Context, pc int, inst *Instruction, operands []Value, err error) {
+	h.events = append(h.events, fmt.Sprintf("after %d %s %v %v", pc, inst.Mnemonic, operands, err))
+}
+
+func (h *recordingHook) Call(ctx context.Context, pc, addr, depth int) {
+	h.events = append(h.events, fmt.Sprintf("call %d %d %d", pc, addr, depth))
+}
+
+func (h *recordingHook) Return(ctx context.Context, pc, addr, depth int) {
+	h.events = append(h.events, fmt.Sprintf("return %d %d %d", pc, addr, depth))
+}
+
+type countingHook struct {
+	NopHook
+	calls int
+}
+
+func (h *countingHook) Call(ctx context.Context, pc, addr, depth int) {
+	h.calls++
+}
+
+func TestHook(t *testing.T) {
+	assert := assert.New(t)
+
+	h1 := &recordingHook{}
+	h2 := &countingHook{}
+	m := NewMachine(WithHook(h1), WithHook(h2))
+
+	p := []Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(2)}},
+		{Mnemonic: MnemonicCall, Immediates: []Value{StringValue("f"), IntegerValue(1)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		{Label: "f", Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	}
+	res, err := m.Run(p, nil)
+	assert.NoError(err)
+	assert.Equal([]Value{IntegerValue(2)}, res)
+	assert.Equal([]string{
+		"before 0 push []",
+		"after 0 push [2] <nil>",
+		"before 1 call [2]",
+		"after 1 call [] <nil>",
+		"call 1 3 2",
+		"before 3 lda []",
+		"after 3 lda [2] <nil>",
+		"before 4 ret [2]",
+		"after 4 ret [2] <nil>",
+		"return 4 2 1",
+		"before 2 ret [2]",
+		"after 2 ret [] <nil>",
+		"return 2 5 0",
+	}, h1.events)
+	assert.Equal(1, h2.calls)
+
+	h1.events = nil
+	_, err = m.Run([]Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicDivide, Immediates: []Value{IntegerValue(0)}},
+	}, nil)
+	assert.Error(err)
+	assert.Equal([]string{
+		"before 0 push []",
+		"after 0 push [1] <nil>",
+		"before 1 div [1]",
+		"after 1 div [1 0] div at 1: divide by zero",
+	}, h1.events)
+}
@@ -1,5 +1,7 @@
 package jsm
 
+import "sync"
+
 // Instruction is an instruction of JSM.
 type Instruction struct {
 	Label      string   `json:"label,omitempty"`
@@ -24,6 +26,10 @@ const (
 	MnemonicStore                   = "st"
 	MnemonicStoreLocal              = "stl"
 	MnemonicCall                    = "call"
+	MnemonicCallValue               = "callv"
+	MnemonicLoadFunction            = "ldf"
+	MnemonicClosure                 = "closure"
+	MnemonicLoadCaptured            = "ldc"
 	MnemonicReturn                  = "ret"
 	MnemonicJump                    = "jmp"
 	MnemonicJumpIfTrue              = "jt"
@@ -46,11 +52,75 @@ const (
 	MnemonicIncrementLocal          = "incl"
 	MnemonicDecrement               = "dec"
 	MnemonicDecrementLocal          = "decl"
+	MnemonicTry                     = "try"
+	MnemonicEndTry                  = "endtry"
+	MnemonicThrow                   = "throw"
+	MnemonicConcat                  = "concat"
+	MnemonicStringLength            = "slen"
+	MnemonicSlice                   = "slice"
+	MnemonicIndexOf                 = "indexof"
+	MnemonicSplit                   = "split"
+	MnemonicJoin                    = "join"
+	MnemonicUpper                   = "upper"
+	MnemonicLower                   = "lower"
+	MnemonicTrim                    = "trim"
+	MnemonicReplace                 = "replace"
+	MnemonicMatch                   = "match"
+	MnemonicArray                   = "array"
+	MnemonicObject                  = "object"
+	MnemonicGet                     = "get"
+	MnemonicSet                     = "set"
+	MnemonicArrayPush               = "apush"
+	MnemonicArrayPop                = "apop"
+	MnemonicLength                  = "len"
+	MnemonicKeys                    = "keys"
+	MnemonicValues                  = "values"
+	MnemonicHas                     = "has"
+	MnemonicDelete                  = "del"
+	MnemonicSpread                  = "spread"
+	MnemonicGetPointer              = "getp"
+	MnemonicSetPointer              = "setp"
+	MnemonicModulo                  = "mod"
+	MnemonicPower                   = "pow"
+	MnemonicIntegerDivide           = "idiv"
+	MnemonicAbs                     = "abs"
+	MnemonicFloor                   = "floor"
+	MnemonicCeil                    = "ceil"
+	MnemonicRound                   = "round"
+	MnemonicTrunc                   = "trunc"
+	MnemonicSqrt                    = "sqrt"
+	MnemonicLog                     = "log"
+	MnemonicExp                     = "exp"
+	MnemonicMin                     = "min"
+	MnemonicMax                     = "max"
+	MnemonicBitwiseAnd              = "band"
+	MnemonicBitwiseOr               = "bor"
+	MnemonicBitwiseXor              = "bxor"
+	MnemonicBitwiseNot              = "bnot"
+	MnemonicShiftLeft               = "shl"
+	MnemonicShiftRight              = "shr"
+	MnemonicUnsignedShift           = "ushr"
+	MnemonicTypeOf                  = "typeof"
+	MnemonicToBoolean               = "tobool"
+	MnemonicToInteger               = "toint"
+	MnemonicToNumber                = "tonum"
+	MnemonicToString                = "tostr"
+	MnemonicIsNull                  = "isnull"
+	MnemonicIsArray                 = "isarray"
+	MnemonicIsObject                = "isobject"
+	MnemonicParse                   = "parse"
+	MnemonicStringify               = "stringify"
 )
 
+// opcodes assigns opcodes to mnemonics.
+// It is shared by all machines, each of which has its own processes indexed by the opcodes.
 var opcodes = map[Mnemonic]int{}
+var opcodesMutex sync.Mutex
 
 func opcode(mnemonic Mnemonic) int {
+	opcodesMutex.Lock()
+	defer opcodesMutex.Unlock()
+
 	opcode, ok := opcodes[mnemonic]
 	if !ok {
 		opcode = len(opcodes)
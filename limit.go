@@ -0,0 +1,107 @@
+package jsm
+
+import "context"
+
+// limits are the limits of the memory used by a machine.
+// The instructions that grow the call stack, operand stacks or heaps check the limits
+// before growing them, and the machine checks them again after each instruction
+// for the instructions that grow them otherwise, such as extensions.
+type limits struct {
+	maxCallDepth int
+	maxOperands  int
+	maxHeapKeys  int
+	maxHeapBytes int
+
+	// heapKeys and heapBytes are the running totals of the global heap
+	// and the local heaps of the frames on the call stack.
+	// heapBytes is counted only if maxHeapBytes is set.
+	heapKeys  int
+	heapBytes int
+}
+
+func (l *limits) enabled() bool {
+	return l.maxCallDepth > 0 || l.maxOperands > 0 || l.maxHeapKeys > 0 || l.maxHeapBytes > 0
+}
+
+func (l *limits) check(m *machine, idx int) error {
+	depth := len(*m.Stack)
+	if err := l.checkCallDepth(depth); err != nil {
+		return atPC(err, idx)
+	}
+
+	if depth > 0 {
+		if err := l.checkOperands((*m.Stack)[depth-1].Operands.len()); err != nil {
+			return atPC(err, idx)
+		}
+	}
+
+	if err := l.checkHeap(l.heapKeys, l.heapBytes); err != nil {
+		return atPC(err, idx)
+	}
+	return nil
+}
+
+func (l *limits) checkCallDepth(depth int) error {
+	if l.maxCallDepth > 0 && depth > l.maxCallDepth {
+		return &CallStackOverflowError{Limit: l.maxCallDepth}
+	}
+	return nil
+}
+
+func (l *limits) checkOperands(n int) error {
+	if l.maxOperands > 0 && n > l.maxOperands {
+		return &OperandStackOverflowError{Limit: l.maxOperands}
+	}
+	return nil
+}
+
+func (l *limits) checkHeap(keys, bytes int) error {
+	if (l.maxHeapKeys > 0 && keys > l.maxHeapKeys) ||
+		(l.maxHeapBytes > 0 && bytes > l.maxHeapBytes) {
+		return &HeapExhaustedError{
+			Keys:     keys,
+			Bytes:    bytes,
+			MaxKeys:  l.maxHeapKeys,
+			MaxBytes: l.maxHeapBytes,
+		}
+	}
+	return nil
+}
+
+// reserveOperands checks that the given number of values can be pushed onto the current operand stack.
+func reserveOperands(ctx context.Context, n int) error {
+	l := getLimits(ctx)
+	if l.maxOperands <= 0 {
+		return nil
+	}
+
+	f, err := getFrame(ctx)
+	if err != nil {
+		return err
+	}
+	return l.checkOperands(f.Operands.len() + n)
+}
+
+// atPC sets the index of the instruction that exceeded a limit to the error of the limit.
+func atPC(err error, idx int) error {
+	switch e := err.(type) {
+	case *CallStackOverflowError:
+		e.PC = idx
+	case *OperandStackOverflowError:
+		e.PC = idx
+	case *HeapExhaustedError:
+		e.PC = idx
+	}
+	return err
+}
+
+// isLimitError reports whether an error is of exceeding a limit,
+// which is returned as it is without being caught by handlers.
+func isLimitError(err error) bool {
+	switch err.(type) {
+	case *CallStackOverflowError, *OperandStackOverflowError, *HeapExhaustedError:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,141 @@
+package jsm
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxCallDepth(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Label: "f", Mnemonic: MnemonicCall, Immediates: []Value{StringValue("f")}},
+	}
+
+	m := NewMachine(WithMaxCallDepth(100))
+	_, err := m.Run(p, nil)
+	assert.Equal(&CallStackOverflowError{PC: 0, Limit: 100}, err)
+}
+
+func TestMaxOperands(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Label: "loop", Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicJump, Immediates: []Value{StringValue("loop")}},
+	}
+
+	m := NewMachine(WithMaxOperands(10))
+	_, err := m.Run(p, nil)
+	assert.Equal(&OperandStackOverflowError{PC: 0, Limit: 10}, err)
+}
+
+func TestMaxHeapKeys(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Label: "loop", Mnemonic: MnemonicIncrement, Immediates: []Value{StringValue("i")}},
+		{Mnemonic: MnemonicLoad, Immediates: []Value{StringValue("i")}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{BooleanValue(true)}},
+		{Mnemonic: MnemonicStoreLocal},
+		{Mnemonic: MnemonicJump, Immediates: []Value{StringValue("loop")}},
+	}
+
+	m := NewMachine(WithMaxHeapKeys(5))
+	_, err := m.Run(p, nil)
+
+	var he *HeapExhaustedError
+	assert.True(errors.As(err, &he))
+	assert.Equal(3, he.PC)
+	assert.Equal(6, he.Keys)
+	assert.Equal(5, he.MaxKeys)
+}
+
+func TestMaxHeapBytes(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicStore, Immediates: []Value{StringValue("a"), StringValue("abc")}},
+		{Mnemonic: MnemonicStoreLocal, Immediates: []Value{StringValue("b"), StringValue("abcdefghijklmnopqrstuvwxyz")}},
+		{Mnemonic: MnemonicReturn},
+	}
+
+	m := NewMachine(WithMaxHeapBytes(60))
+	_, err := m.Run(p, nil)
+	assert.Equal(&HeapExhaustedError{PC: 1, Keys: 2, Bytes: 95, MaxBytes: 60}, err)
+
+	m = NewMachine(WithMaxHeapBytes(100))
+	_, err = m.Run(p, nil)
+	assert.NoError(err)
+}
+
+func TestLimitsBeforeGrowing(t *testing.T) {
+	assert := assert.New(t)
+
+	m := NewMachine(WithMaxOperands(3)).(*machine)
+	_, err := m.Run([]Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(2), IntegerValue(3), IntegerValue(4)}},
+	}, nil)
+	assert.Equal(&OperandStackOverflowError{PC: 1, Limit: 3}, err)
+	assert.Equal([]Value{IntegerValue(1)}, m.operands())
+
+	m = NewMachine(WithMaxHeapBytes(100)).(*machine)
+	_, err = m.Run([]Instruction{
+		{Mnemonic: MnemonicStore, Immediates: []Value{StringValue("a"), StringValue(strings.Repeat("x", 100))}},
+	}, nil)
+	assert.Equal(&HeapExhaustedError{PC: 0, Keys: 1, Bytes: 133, MaxBytes: 100}, err)
+	assert.Empty(m.Heap.values)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicTry, Immediates: []Value{StringValue("catch")}},
+		{Mnemonic: MnemonicStore, Immediates: []Value{StringValue("a"), IntegerValue(1)}},
+		{Mnemonic: MnemonicStore, Immediates: []Value{StringValue("b"), IntegerValue(2)}},
+		{Mnemonic: MnemonicReturn},
+		{Label: "catch", Mnemonic: MnemonicReturn},
+	}
+	m = NewMachine(WithMaxHeapKeys(1)).(*machine)
+	_, err = m.Run(p, nil)
+	assert.Equal(&HeapExhaustedError{PC: 2, Keys: 2, MaxKeys: 1}, err)
+}
+
+func TestLimitsReleaseLocals(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicStoreLocal, Immediates: []Value{StringValue("i"), IntegerValue(10)}},
+		{Label: "loop", Mnemonic: MnemonicCall, Immediates: []Value{StringValue("f")}},
+		{Mnemonic: MnemonicDecrementLocal, Immediates: []Value{StringValue("i")}},
+		{Mnemonic: MnemonicLoadLocal, Immediates: []Value{StringValue("i")}},
+		{Mnemonic: MnemonicJumpIfTrue, Immediates: []Value{StringValue("loop")}},
+		{Mnemonic: MnemonicReturn},
+		{Label: "f", Mnemonic: MnemonicStoreLocal, Immediates: []Value{StringValue("a"), StringValue("abc")}},
+		{Mnemonic: MnemonicStoreLocal, Immediates: []Value{StringValue("b"), StringValue("def")}},
+		{Mnemonic: MnemonicReturn},
+	}
+
+	m := NewMachine(WithMaxHeapKeys(3), WithMaxHeapBytes(200)).(*machine)
+	_, err := m.Run(p, nil)
+	assert.NoError(err)
+	assert.Equal(0, m.limits.heapKeys)
+	assert.Equal(0, m.limits.heapBytes)
+
+	d := NewDebugger(m)
+	assert.NoError(d.Load(p, nil))
+	for i := 0; i < 3; i++ {
+		_, err := d.Step()
+		assert.NoError(err)
+	}
+	assert.Equal(2, m.limits.heapKeys)
+
+	data, err := m.Dump()
+	assert.NoError(err)
+
+	m2 := NewMachine(WithMaxHeapKeys(3), WithMaxHeapBytes(200)).(*machine)
+	assert.NoError(m2.Restore(data))
+	assert.Equal(m.limits.heapKeys, m2.limits.heapKeys)
+	assert.Equal(m.limits.heapBytes, m2.limits.heapBytes)
+}
@@ -14,12 +14,40 @@ type Machine interface {
 
 	Run(program []Instruction, args []Value) (Value, error)
 
+	// RunContext is like Run but stops the execution with an InterruptedError
+	// as soon as the given context is canceled or its deadline is exceeded.
+	RunContext(ctx context.Context, program []Instruction, args []Value) (Value, error)
+
 	Extend(mnemonic Mnemonic, process Process, preprocess Preprocess) error
+
+	// Bind defines an instruction that calls the given Go function.
+	// The instruction pops as many operands as the function has parameters,
+	// or as many as its immediate specifies if the function is variadic,
+	// converts them to the parameter types, and pushes the result if any.
+	// A first parameter of type context.Context receives the machine context,
+	// and an error returned as the last result makes the instruction fail.
+	Bind(mnemonic Mnemonic, fn interface{}) error
+
+	// Override replaces an instruction that is already defined, including a built-in one.
+	Override(mnemonic Mnemonic, process Process, preprocess Preprocess) error
+
+	// Remove removes an instruction, so that programs using it fail to run.
+	Remove(mnemonic Mnemonic) error
+
+	// Mnemonics returns the sorted mnemonics of the defined instructions.
+	Mnemonics() []Mnemonic
+
+	// ConsumedGas returns the amount of gas consumed by the last run.
+	ConsumedGas() int
 }
 
 // NewMachine creates a new Machine.
-func NewMachine() Machine {
-	return newMachine()
+func NewMachine(opts ...Option) Machine {
+	m := newMachine()
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
 }
 
 type machine struct {
@@ -31,26 +59,73 @@ type machine struct {
 	Heap    *heap           `json:"heap"`
 	Stack   *callStack      `json:"stack"`
 
+	// Origins are the indices of the original instructions of an optimized program.
+	Origins []int `json:"origins,omitempty"`
+
+	source  []Instruction
+	effects map[Mnemonic]stackEffect
+
+	// replaced contains the mnemonics of the built-in instructions replaced by options,
+	// which keep their stack effects and control flow but compute different results.
+	replaced map[Mnemonic]bool
+
+	gas     *gasMeter
+	limits  *limits
+	hooks   hooks
 	context context.Context
+
+	verification verification
+	optimization bool
+	compilation  bool
+	compiled     []compiled
 }
 
 func newMachine() *machine {
 	m := new(machine)
 	m.processor = newProcessor()
 	m.preprocessor = newPreprocessor()
+	m.effects = map[Mnemonic]stackEffect{}
+	m.replaced = map[Mnemonic]bool{}
+	m.verification = verifyStack
 	m.PC = newProgramCounter()
 	m.Heap = newHeap()
 	m.Stack = newCallStack()
+	m.gas = newGasMeter()
+	m.limits = new(limits)
+	m.Heap.attach(m.limits)
 	m.context = newMachineContext(m)
 	return m
 }
 
 func (m *machine) Run(program []Instruction, args []Value) (Value, error) {
-	if err := m.load(program, args); err != nil {
+	return m.RunContext(context.Background(), program, args)
+}
+
+func (m *machine) RunContext(ctx context.Context, program []Instruction, args []Value) (Value, error) {
+	if err := m.load(program, args, m.optimization); err != nil {
 		return NullValue(), err
 	}
 
+	setParent(m.context, ctx)
+	defer setParent(m.context, context.Background())
+
+	done := ctx.Done()
+	if m.chainable() {
+		if err := m.chain(ctx, done); err != nil {
+			return NullValue(), err
+		}
+		return getResult(m.context), nil
+	}
+
 	for m.inProgress() {
+		if done != nil {
+			select {
+			case <-done:
+				return NullValue(), m.interrupted(ctx.Err())
+			default:
+			}
+		}
+
 		if err := m.step(); err != nil {
 			return NullValue(), err
 		}
@@ -59,20 +134,36 @@ func (m *machine) Run(program []Instruction, args []Value) (Value, error) {
 	return getResult(m.context), nil
 }
 
-func (m *machine) load(program []Instruction, args []Value) error {
+func (m *machine) load(program []Instruction, args []Value, optimization bool) error {
 	p, err := m.preprocessor.preprocess(program)
 	if err != nil {
 		return err
 	}
 
+	if err := verify(p, m.effects, m.verification); err != nil {
+		return err
+	}
+
+	var origins []int
+	if optimization {
+		p, origins = optimize(p, m.effects, m.replaced)
+	}
+
 	if args == nil {
 		args = []Value{}
 	}
 
 	m.Clear()
 	m.Program = p
+	m.source = program
+	m.Origins = origins
+	if m.compilation {
+		m.compiled = m.compile(p)
+	}
+	m.gas.load(p)
 
 	frame := newFrame()
+	frame.Locals.attach(m.limits)
 	frame.Arguments = args
 	frame.ReturnTo = len(p)
 	m.Stack.Push(frame)
@@ -84,8 +175,121 @@ func (m *machine) inProgress() bool {
 	return idx >= 0 && idx < len(m.Program)
 }
 
+func (m *machine) interrupted(err error) error {
+	return &InterruptedError{
+		PC:    m.origin(m.PC.Index()),
+		Depth: len(*m.Stack),
+		Err:   err,
+	}
+}
+
 func (m *machine) step() error {
-	return m.processor.process(m.context, &m.Program[m.PC.Index()])
+	idx := m.PC.Index()
+	inst := &m.Program[idx]
+	if err := m.gas.consume(idx, inst); err != nil {
+		if oog, ok := err.(*OutOfGasError); ok {
+			oog.PC = m.origin(idx)
+		}
+		return err
+	}
+
+	if m.hooks == nil {
+		return m.execute(idx, inst)
+	}
+
+	depth := len(*m.Stack)
+	m.hooks.before(m.context, idx, inst, m.operands())
+	err := m.execute(idx, inst)
+	m.hooks.after(m.context, idx, inst, m.operands(), err)
+	if err == nil {
+		m.hooks.transit(m.context, idx, depth, len(*m.Stack))
+	}
+	return err
+}
+
+func (m *machine) execute(idx int, inst *Instruction) error {
+	var err error
+	if idx < len(m.compiled) {
+		err = m.compiled[idx](m.context)
+	} else {
+		err = m.processor.process(m.context, inst)
+	}
+
+	if err != nil {
+		if isLimitError(err) {
+			return atPC(err, m.origin(idx))
+		}
+
+		re := m.runtimeError(idx, inst, err)
+		if !m.catch(re) {
+			return re
+		}
+	}
+
+	if m.limits.enabled() {
+		return m.limits.check(m, m.origin(idx))
+	}
+	return nil
+}
+
+func (m *machine) runtimeError(idx int, inst *Instruction, err error) *RuntimeError {
+	idx = m.origin(idx)
+	re := &RuntimeError{
+		Err:      err,
+		PC:       idx,
+		Mnemonic: inst.Mnemonic,
+		Label:    m.label(idx),
+	}
+	if idx < len(m.source) {
+		re.Comment = m.source[idx].Comment
+	}
+
+	re.StackTrace = append(re.StackTrace, Location{PC: idx, Label: re.Label})
+	cs := *m.Stack
+	for i := len(cs) - 1; i > 0; i-- {
+		pc := m.origin(cs[i].ReturnTo - 1)
+		re.StackTrace = append(re.StackTrace, Location{PC: pc, Label: m.label(pc)})
+	}
+	return re
+}
+
+// origin returns the index of the original instruction of an optimized one.
+func (m *machine) origin(idx int) int {
+	if m.Origins == nil || idx < 0 {
+		return idx
+	}
+
+	if idx >= len(m.Origins) {
+		return len(m.source)
+	}
+	return m.Origins[idx]
+}
+
+func (m *machine) label(idx int) string {
+	if idx >= len(m.source) {
+		idx = len(m.source) - 1
+	}
+
+	for ; idx >= 0; idx-- {
+		if l := m.source[idx].Label; l != "" {
+			return l
+		}
+	}
+	return ""
+}
+
+func (m *machine) operands() []Value {
+	f, err := m.Stack.Peek()
+	if err != nil {
+		return nil
+	}
+	return f.Operands.toValues()
+}
+
+// replace replaces the process of a built-in instruction for an option.
+func (m *machine) replace(mnemonic Mnemonic, process Process) {
+	m.processor.replace(mnemonic, process)
+	m.replaced[mnemonic] = true
 }
 
 func (m *machine) Extend(mnemonic Mnemonic, process Process, preprocess Preprocess) error {
@@ -95,11 +299,58 @@ func (m *machine) Extend(mnemonic Mnemonic, process Process, preprocess Preproce
 	return m.preprocessor.extend(mnemonic, preprocess)
 }
 
+func (m *machine) Bind(mnemonic Mnemonic, fn interface{}) error {
+	b, err := newBinding(fn)
+	if err != nil {
+		return err
+	}
+
+	if err := m.Extend(mnemonic, b.process, b.preprocess); err != nil {
+		return err
+	}
+
+	m.effects[mnemonic] = b.effect
+	return nil
+}
+
+func (m *machine) Override(mnemonic Mnemonic, process Process, preprocess Preprocess) error {
+	if err := m.processor.override(mnemonic, process); err != nil {
+		return err
+	}
+
+	m.preprocessor.override(mnemonic, preprocess)
+	m.effects[mnemonic] = nil
+	return nil
+}
+
+func (m *machine) Remove(mnemonic Mnemonic) error {
+	if err := m.processor.remove(mnemonic); err != nil {
+		return err
+	}
+
+	m.preprocessor.override(mnemonic, nil)
+	m.effects[mnemonic] = nil
+	return nil
+}
+
+func (m *machine) Mnemonics() []Mnemonic {
+	return m.processor.mnemonics()
+}
+
+func (m *machine) ConsumedGas() int {
+	return m.gas.consumed
+}
+
 func (m *machine) Clear() {
 	m.Program = nil
+	m.source = nil
+	m.Origins = nil
+	m.compiled = nil
 	m.PC.Clear()
 	m.Heap.Clear()
 	m.Stack.Clear()
+	setResult(m.context, NullValue())
+	clearRegexps(m.context)
 }
 
 func (m *machine) Dump() ([]byte, error) {
@@ -107,6 +358,24 @@ func (m *machine) Dump() ([]byte, error) {
 	return data, errors.Wrap(err, "failed to dump machine")
 }
 
+// Restore restores the state dumped by Dump.
+// The opcodes of the restored program are not dumped, so they are recomputed
+// to resume the execution with the processes of this machine.
 func (m *machine) Restore(data []byte) error {
-	return errors.Wrap(json.Unmarshal(data, m), "failed to restore machine")
+	m.Origins = nil
+	m.compiled = nil
+	if err := json.Unmarshal(data, m); err != nil {
+		return errors.Wrap(err, "failed to restore machine")
+	}
+
+	m.limits.heapKeys, m.limits.heapBytes = 0, 0
+	m.Heap.attach(m.limits)
+	for _, f := range *m.Stack {
+		f.Locals.attach(m.limits)
+	}
+
+	for idx := range m.Program {
+		m.Program[idx].opcode = opcode(m.Program[idx].Mnemonic)
+	}
+	return nil
 }
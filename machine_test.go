@@ -3,9 +3,13 @@ package jsm
 import (
 	"context"
 	"encoding/json"
+	"fmt"
 	"io/ioutil"
+	"sort"
 	"testing"
+	"time"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -49,6 +53,136 @@ func TestMachineRunSum(t *testing.T) {
 	assert.Equal([]Value{NumberValue(55.0)}, res)
 }
 
+func TestMachineRunMap(t *testing.T) {
+	assert := assert.New(t)
+
+	j, err := ioutil.ReadFile("./examples/map.json")
+	assert.NoError(err)
+
+	var p []Instruction
+	err = json.Unmarshal(j, &p)
+	assert.NoError(err)
+
+	m := NewMachine()
+	res, err := m.Run(p, []Value{ArrayValue([]Value{})})
+	assert.NoError(err)
+	assert.Equal([]Value{ArrayValue([]Value{})}, res)
+
+	res, err = m.Run(p, []Value{ArrayValue([]Value{NumberValue(1.0), NumberValue(2.0), NumberValue(3.0)})})
+	assert.NoError(err)
+	assert.Equal([]Value{ArrayValue([]Value{NumberValue(1.0), NumberValue(4.0), NumberValue(9.0)})}, res)
+}
+
+func TestMachineCallValue(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(2)}},
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicCallValue, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		{Label: "inc", Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicAdd, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		{Label: "dec", Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicSubtract, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	}
+
+	m := NewMachine()
+	res, err := m.Run(p, []Value{IntegerValue(4)})
+	assert.NoError(err)
+	assert.Equal([]Value{NumberValue(3.0)}, res)
+
+	res, err = m.Run(p, []Value{IntegerValue(7)})
+	assert.NoError(err)
+	assert.Equal([]Value{NumberValue(1.0)}, res)
+
+	_, err = m.Run(p, []Value{StringValue("inc")})
+	assert.EqualError(err, "callv at 2: not a function")
+
+	_, err = m.Run(p, []Value{IntegerValue(10)})
+	assert.EqualError(err, "callv at 2: invalid address")
+
+	_, err = m.Run([]Instruction{
+		{Mnemonic: MnemonicLoadFunction, Immediates: []Value{StringValue("none")}},
+	}, nil)
+	assert.Error(err)
+}
+
+func TestMachineRunContext(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicNop},
+		{Label: "loop", Mnemonic: MnemonicJump, Immediates: []Value{StringValue("loop")}},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+
+	m := NewMachine()
+	_, err := m.RunContext(ctx, p, nil)
+	assert.Error(err)
+
+	var ie *InterruptedError
+	assert.True(errors.As(err, &ie))
+	assert.Equal(1, ie.PC)
+	assert.Equal(1, ie.Depth)
+	assert.Equal(context.DeadlineExceeded, errors.Cause(err))
+
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	_, err = m.RunContext(ctx, p, nil)
+	assert.True(errors.As(err, &ie))
+	assert.Equal(0, ie.PC)
+	assert.Equal(context.Canceled, ie.Err)
+
+	res, err := m.RunContext(context.Background(), []Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	}, nil)
+	assert.NoError(err)
+	assert.Equal([]Value{IntegerValue(1)}, res)
+}
+
+func TestMachineGas(t *testing.T) {
+	assert := assert.New(t)
+
+	j, err := ioutil.ReadFile("./examples/sum_of_series.json")
+	assert.NoError(err)
+
+	var p []Instruction
+	err = json.Unmarshal(j, &p)
+	assert.NoError(err)
+
+	m := NewMachine()
+	_, err = m.Run(p, []Value{NumberValue(10.0)})
+	assert.NoError(err)
+	assert.Equal(87, m.ConsumedGas())
+
+	m = NewMachine(WithGasLimit(100), WithGasCost(MnemonicJump, 5))
+	_, err = m.Run(p, []Value{NumberValue(10.0)})
+	assert.Error(err)
+
+	var oog *OutOfGasError
+	assert.True(errors.As(err, &oog))
+	assert.Equal(100, oog.Limit)
+	assert.True(oog.Consumed <= 100)
+	assert.Equal(oog.Consumed, m.ConsumedGas())
+
+	m = NewMachine(WithGasLimit(10), WithGasCost("fib", 10))
+	err = m.Extend("fib", fib, nil)
+	assert.NoError(err)
+
+	_, err = m.Run([]Instruction{
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: "fib"},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	}, []Value{IntegerValue(7)})
+	assert.Equal(&OutOfGasError{PC: 1, Limit: 10, Consumed: 1}, err)
+}
+
 func fibonacci(n int) int {
 	if n < 2 {
 		return n
@@ -127,6 +261,7 @@ func TestMachineExtendSum(t *testing.T) {
 }
 
 func BenchmarkFibJSM(b *testing.B) {
+	b.ReportAllocs()
 	m := NewMachine()
 
 	var p []Instruction
@@ -140,6 +275,7 @@ func BenchmarkFibJSM(b *testing.B) {
 }
 
 func BenchmarkFibNative(b *testing.B) {
+	b.ReportAllocs()
 	m := NewMachine()
 	m.Extend("fib", fib, nil)
 
@@ -156,6 +292,7 @@ func BenchmarkFibNative(b *testing.B) {
 }
 
 func BenchmarkSumJSM(b *testing.B) {
+	b.ReportAllocs()
 	m := NewMachine()
 
 	var p []Instruction
@@ -169,6 +306,7 @@ func BenchmarkSumJSM(b *testing.B) {
 }
 
 func BenchmarkSumNative(b *testing.B) {
+	b.ReportAllocs()
 	m := NewMachine()
 	m.Extend("sum", sum, nil)
 
@@ -183,3 +321,327 @@ func BenchmarkSumNative(b *testing.B) {
 		m.Run(p, []Value{IntegerValue(100000)})
 	}
 }
+
+func BenchmarkHeapAccess(b *testing.B) {
+	b.ReportAllocs()
+	m := NewMachine()
+
+	p := []Instruction{
+		{Mnemonic: MnemonicStoreLocal, Immediates: []Value{StringValue("i"), IntegerValue(0)}},
+		{Label: "loop", Mnemonic: MnemonicLoadLocal, Immediates: []Value{StringValue("i")}},
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicLessThan},
+		{Mnemonic: MnemonicJumpIfFalse, Immediates: []Value{StringValue("exit")}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{StringValue("x")}},
+		{Mnemonic: MnemonicLoadLocal, Immediates: []Value{StringValue("i")}},
+		{Mnemonic: MnemonicStore},
+		{Mnemonic: MnemonicPush, Immediates: []Value{StringValue("y")}},
+		{Mnemonic: MnemonicLoad, Immediates: []Value{StringValue("x")}},
+		{Mnemonic: MnemonicStoreLocal},
+		{Mnemonic: MnemonicIncrementLocal, Immediates: []Value{StringValue("i")}},
+		{Mnemonic: MnemonicJump, Immediates: []Value{StringValue("loop")}},
+		{Label: "exit", Mnemonic: MnemonicLoadLocal, Immediates: []Value{StringValue("y")}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := m.Run(p, []Value{IntegerValue(10000)}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDeepRecursion(b *testing.B) {
+	b.ReportAllocs()
+	m := NewMachine()
+
+	p := []Instruction{
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicCall, Immediates: []Value{StringValue("f"), IntegerValue(1)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		{Label: "f", Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicJumpIfTrue, Immediates: []Value{StringValue("rec")}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		{Label: "rec", Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicSubtract, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicCall, Immediates: []Value{StringValue("f"), IntegerValue(1)}},
+		{Mnemonic: MnemonicAdd, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := m.Run(p, []Value{IntegerValue(10000)}); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+// newLargeMachine returns a machine whose global heap has n entries.
+func newLargeMachine(b *testing.B, n int) Machine {
+	m := NewMachine()
+
+	p := []Instruction{
+		{Mnemonic: MnemonicStoreLocal, Immediates: []Value{StringValue("i"), IntegerValue(0)}},
+		{Label: "loop", Mnemonic: MnemonicLoadLocal, Immediates: []Value{StringValue("i")}},
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicLessThan},
+		{Mnemonic: MnemonicJumpIfFalse, Immediates: []Value{StringValue("exit")}},
+		{Mnemonic: MnemonicLoadLocal, Immediates: []Value{StringValue("i")}},
+		{Mnemonic: MnemonicLoadLocal, Immediates: []Value{StringValue("i")}},
+		{Mnemonic: MnemonicStore},
+		{Mnemonic: MnemonicIncrementLocal, Immediates: []Value{StringValue("i")}},
+		{Mnemonic: MnemonicJump, Immediates: []Value{StringValue("loop")}},
+		{Label: "exit", Mnemonic: MnemonicReturn},
+	}
+
+	if _, err := m.Run(p, []Value{IntegerValue(n)}); err != nil {
+		b.Fatal(err)
+	}
+	return m
+}
+
+func BenchmarkDump(b *testing.B) {
+	b.ReportAllocs()
+	m := newLargeMachine(b, 10000)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := m.Dump(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkRestore(b *testing.B) {
+	b.ReportAllocs()
+	data, err := newLargeMachine(b, 10000).Dump()
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	m := NewMachine()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := m.Restore(data); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestMachineRestoreAndResume(t *testing.T) {
+	assert := assert.New(t)
+
+	j, err := ioutil.ReadFile("./examples/fibonacci.json")
+	assert.NoError(err)
+
+	var p []Instruction
+	err = json.Unmarshal(j, &p)
+	assert.NoError(err)
+
+	m := NewMachine()
+	d := NewDebugger(m)
+	assert.NoError(d.Load(p, []Value{NumberValue(7.0)}))
+	for i := 0; i < 20; i++ {
+		_, err := d.Step()
+		assert.NoError(err)
+	}
+
+	data, err := m.Dump()
+	assert.NoError(err)
+
+	m2 := newMachine()
+	assert.NoError(m2.Restore(data))
+	for m2.inProgress() {
+		assert.NoError(m2.step())
+	}
+	assert.Equal([]Value{NumberValue(13.0)}, getResult(m2.context))
+}
+
+func TestMachineRuntimeError(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicCall, Immediates: []Value{StringValue("f"), IntegerValue(1)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		{Label: "f", Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicCall, Immediates: []Value{StringValue("g"), IntegerValue(1)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		{Label: "g", Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicDivide, Comment: "1 / x"},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	}
+
+	m := NewMachine()
+	_, err := m.Run(p, nil)
+	assert.EqualError(err, "div at 8 (g): divide by zero")
+
+	var re *RuntimeError
+	assert.True(errors.As(err, &re))
+	assert.Equal(8, re.PC)
+	assert.Equal(Mnemonic(MnemonicDivide), re.Mnemonic)
+	assert.Equal("g", re.Label)
+	assert.Equal("1 / x", re.Comment)
+	assert.Equal([]Location{{PC: 8, Label: "g"}, {PC: 4, Label: "f"}, {PC: 1}}, re.StackTrace)
+	assert.Equal("divide by zero", errors.Cause(err).Error())
+
+	_, err = m.Run([]Instruction{{Mnemonic: "none"}}, nil)
+	assert.EqualError(err, "none at 0: cannot process none")
+}
+
+func TestMachineOverride(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(7)}},
+		{Mnemonic: MnemonicDivide, Immediates: []Value{IntegerValue(2)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	}
+
+	m1 := NewMachine()
+	err := m1.Override(MnemonicDivide, binaryOp(func(vs []Value) (Value, error) {
+		return idiv(vs)
+	}), atMostOneInteger)
+	assert.NoError(err)
+
+	res, err := m1.Run(p, nil)
+	assert.NoError(err)
+	assert.Equal([]Value{NumberValue(3)}, res)
+
+	m2 := NewMachine()
+	res, err = m2.Run(p, nil)
+	assert.NoError(err)
+	assert.Equal([]Value{NumberValue(3.5)}, res)
+
+	assert.EqualError(m1.Override("none", fib, nil), "none not defined")
+	assert.EqualError(m1.Override(MnemonicDivide, nil, nil), "no process")
+}
+
+func TestMachineOverrideControl(t *testing.T) {
+	assert := assert.New(t)
+
+	skip := func(ctx context.Context, imms []Value) error {
+		GetProgramCounter(ctx).Increment()
+		return nil
+	}
+	answer := func(ctx context.Context, imms []Value) error {
+		if err := doPush(ctx, IntegerValue(42)); err != nil {
+			return err
+		}
+
+		GetProgramCounter(ctx).Increment()
+		return nil
+	}
+
+	tests := []struct {
+		mnemonic Mnemonic
+		process  Process
+		program  []Instruction
+		res      Value
+	}{
+		{MnemonicJump, skip, []Instruction{
+			{Mnemonic: MnemonicJump},
+			{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+			{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		}, []Value{IntegerValue(1)}},
+		{MnemonicJumpIfTrue, skip, []Instruction{
+			{Mnemonic: MnemonicJumpIfTrue, Immediates: []Value{IntegerValue(-1)}},
+			{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+			{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		}, []Value{IntegerValue(1)}},
+		{MnemonicCall, answer, []Instruction{
+			{Mnemonic: MnemonicCall},
+			{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		}, []Value{IntegerValue(42)}},
+		{MnemonicTry, skip, []Instruction{
+			{Mnemonic: MnemonicTry, Immediates: []Value{StringValue("none")}},
+			{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+			{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		}, []Value{IntegerValue(1)}},
+		{MnemonicLoadFunction, answer, []Instruction{
+			{Mnemonic: MnemonicLoadFunction, Immediates: []Value{IntegerValue(9)}},
+			{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		}, []Value{IntegerValue(42)}},
+		{MnemonicClosure, answer, []Instruction{
+			{Mnemonic: MnemonicClosure},
+			{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		}, []Value{IntegerValue(42)}},
+	}
+
+	for _, opt := range []Option{WithVerification(), WithStrictVerification(), WithOptimization(), WithCompilation()} {
+		for _, test := range tests {
+			m := NewMachine(opt)
+			assert.NoError(m.Override(test.mnemonic, test.process, noPreprocessing))
+
+			res, err := m.Run(test.program, nil)
+			assert.NoError(err, test.mnemonic)
+			assert.Equal(test.res, res, test.mnemonic)
+		}
+	}
+}
+
+func TestMachineRemoveControl(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, mnemonic := range []Mnemonic{MnemonicJump, MnemonicCall, MnemonicTry, MnemonicReturn} {
+		for _, opt := range []Option{WithVerification(), WithStrictVerification(), WithOptimization(), WithCompilation()} {
+			m := NewMachine(opt)
+			assert.NoError(m.Remove(mnemonic))
+
+			_, err := m.Run([]Instruction{{Mnemonic: mnemonic}, {Mnemonic: MnemonicReturn}}, nil)
+			assert.EqualError(err, fmt.Sprintf("%s at 0: cannot process %s", mnemonic, mnemonic))
+		}
+	}
+}
+
+func TestMachineRemove(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{StringValue("x"), IntegerValue(0)}},
+		{Mnemonic: MnemonicStore},
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	}
+	args := []Value{IntegerValue(1)}
+
+	m1 := NewMachine()
+	assert.NoError(m1.Remove(MnemonicStore))
+	assert.NotContains(m1.Mnemonics(), Mnemonic(MnemonicStore))
+	assert.EqualError(m1.Remove(MnemonicStore), "st not defined")
+
+	_, err := m1.Run(p, args)
+	assert.EqualError(err, "st at 1: cannot process st")
+
+	m2 := NewMachine()
+	assert.Contains(m2.Mnemonics(), Mnemonic(MnemonicStore))
+
+	res, err := m2.Run(p, args)
+	assert.NoError(err)
+	assert.Equal([]Value{IntegerValue(1)}, res)
+
+	assert.NoError(m1.Extend(MnemonicStore, fib, nil))
+	assert.Contains(m1.Mnemonics(), Mnemonic(MnemonicStore))
+}
+
+func TestMachineMnemonics(t *testing.T) {
+	assert := assert.New(t)
+
+	m := NewMachine()
+	ms := m.Mnemonics()
+	assert.Contains(ms, Mnemonic(MnemonicPush))
+	assert.Contains(ms, Mnemonic(MnemonicReturn))
+	assert.NotContains(ms, Mnemonic("fib"))
+	assert.True(sort.SliceIsSorted(ms, func(i, j int) bool {
+		return ms[i] < ms[j]
+	}))
+
+	assert.NoError(m.Extend("fib", fib, nil))
+	assert.Len(m.Mnemonics(), len(ms)+1)
+	assert.Contains(m.Mnemonics(), Mnemonic("fib"))
+	assert.NotContains(NewMachine().Mnemonics(), Mnemonic("fib"))
+}
@@ -0,0 +1,68 @@
+package jsm
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// The math instructions follow the semantics of the corresponding JavaScript operators
+// and Math functions, including the handling of NaN, infinities and negative zero.
+
+func mod(vs []Value) (Value, error) {
+	return NumberValue(math.Mod(ToNumber(vs[0]), ToNumber(vs[1]))), nil
+}
+
+func pow(vs []Value) (Value, error) {
+	x := ToNumber(vs[0])
+	y := ToNumber(vs[1])
+	if math.IsNaN(y) || (math.Abs(x) == 1.0 && math.IsInf(y, 0)) {
+		return NumberValue(math.NaN()), nil
+	}
+	return NumberValue(math.Pow(x, y)), nil
+}
+
+func idiv(vs []Value) (Value, error) {
+	num1 := ToNumber(vs[0])
+	num2 := ToNumber(vs[1])
+	if num2 == 0.0 {
+		return NullValue(), errors.New("divide by zero")
+	}
+
+	return NumberValue(math.Trunc(num1 / num2)), nil
+}
+
+func mathFunc(f func(float64) float64) func([]Value) (Value, error) {
+	return func(vs []Value) (Value, error) {
+		return NumberValue(f(ToNumber(vs[0]))), nil
+	}
+}
+
+// round rounds half up like Math.round, whereas math.Round rounds half away from zero.
+func round(f float64) float64 {
+	r := math.Round(f)
+	if f-r == 0.5 {
+		r += 1.0
+	}
+
+	if r == 0.0 && math.Signbit(f) {
+		return math.Copysign(0.0, -1.0)
+	}
+	return r
+}
+
+func minimum(vs []Value) (Value, error) {
+	res := math.Inf(1)
+	for _, v := range vs {
+		res = math.Min(res, ToNumber(v))
+	}
+	return NumberValue(res), nil
+}
+
+func maximum(vs []Value) (Value, error) {
+	res := math.Inf(-1)
+	for _, v := range vs {
+		res = math.Max(res, ToNumber(v))
+	}
+	return NumberValue(res), nil
+}
@@ -0,0 +1,92 @@
+package jsm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMathOperations(t *testing.T) {
+	assert := assert.New(t)
+
+	nan := math.NaN()
+	inf := math.Inf(1)
+	negZero := math.Copysign(0.0, -1.0)
+
+	tests := []struct {
+		op  func([]Value) (Value, error)
+		vs  []Value
+		res float64
+	}{
+		{mod, []Value{NumberValue(5.5), IntegerValue(2)}, 1.5},
+		{mod, []Value{IntegerValue(-5), IntegerValue(3)}, -2.0},
+		{mod, []Value{IntegerValue(5), IntegerValue(0)}, nan},
+		{mod, []Value{NumberValue(inf), IntegerValue(2)}, nan},
+		{mod, []Value{IntegerValue(5), NumberValue(inf)}, 5.0},
+		{pow, []Value{IntegerValue(2), IntegerValue(10)}, 1024.0},
+		{pow, []Value{StringValue("4"), NumberValue(0.5)}, 2.0},
+		{pow, []Value{IntegerValue(1), NumberValue(nan)}, nan},
+		{pow, []Value{IntegerValue(-1), NumberValue(inf)}, nan},
+		{pow, []Value{NumberValue(nan), IntegerValue(0)}, 1.0},
+		{idiv, []Value{IntegerValue(7), IntegerValue(2)}, 3.0},
+		{idiv, []Value{IntegerValue(-7), IntegerValue(2)}, -3.0},
+		{mathFunc(math.Abs), []Value{IntegerValue(-3)}, 3.0},
+		{mathFunc(math.Floor), []Value{NumberValue(-1.5)}, -2.0},
+		{mathFunc(math.Ceil), []Value{NumberValue(-1.5)}, -1.0},
+		{mathFunc(round), []Value{NumberValue(2.5)}, 3.0},
+		{mathFunc(round), []Value{NumberValue(-2.5)}, -2.0},
+		{mathFunc(round), []Value{NumberValue(-2.6)}, -3.0},
+		{mathFunc(round), []Value{NumberValue(0.49999999999999994)}, 0.0},
+		{mathFunc(round), []Value{NumberValue(-0.5)}, negZero},
+		{mathFunc(math.Trunc), []Value{NumberValue(-1.5)}, -1.0},
+		{mathFunc(math.Sqrt), []Value{IntegerValue(-1)}, nan},
+		{mathFunc(math.Log), []Value{IntegerValue(0)}, math.Inf(-1)},
+		{mathFunc(math.Exp), []Value{IntegerValue(0)}, 1.0},
+		{minimum, []Value{IntegerValue(3), IntegerValue(1), IntegerValue(2)}, 1.0},
+		{minimum, []Value{}, inf},
+		{minimum, []Value{IntegerValue(0), NumberValue(negZero)}, negZero},
+		{maximum, []Value{IntegerValue(3), StringValue("x")}, nan},
+		{maximum, []Value{}, math.Inf(-1)},
+		{maximum, []Value{NumberValue(negZero), IntegerValue(0)}, 0.0},
+	}
+
+	for _, test := range tests {
+		res, err := test.op(test.vs)
+		assert.NoError(err)
+		assert.Equal(floatToString(test.res), ToString(res), "%v", test.vs)
+		if !math.IsNaN(test.res) {
+			assert.Equal(math.Signbit(test.res), math.Signbit(ToNumber(res)), "%v", test.vs)
+		}
+	}
+
+	_, err := idiv([]Value{IntegerValue(1), IntegerValue(0)})
+	assert.EqualError(err, "divide by zero")
+}
+
+func TestMathInstructions(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(3), IntegerValue(4)}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(5)}},
+		{Mnemonic: MnemonicMax, Immediates: []Value{IntegerValue(3)}},
+		{Mnemonic: MnemonicPower, Immediates: []Value{IntegerValue(2)}},
+		{Mnemonic: MnemonicSqrt},
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicMin},
+		{Mnemonic: MnemonicModulo, Immediates: []Value{IntegerValue(3)}},
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicIntegerDivide, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(2)}},
+	}
+
+	m := NewMachine()
+	_, err := m.Run(p, []Value{IntegerValue(4)})
+	assert.EqualError(err, "idiv at 9: divide by zero")
+
+	p[9].Immediates = []Value{IntegerValue(3)}
+	res, err := m.Run(p, []Value{IntegerValue(4)})
+	assert.NoError(err)
+	assert.Equal([]Value{NumberValue(1.0), NumberValue(1.0)}, res)
+}
@@ -0,0 +1,407 @@
+package jsm
+
+// The optimizer rewrites a preprocessed program into an equivalent one that executes fewer instructions.
+// It performs the following peephole optimizations until the program no longer changes:
+//
+//   - constant folding of pure operations on pushed values
+//   - fusion of a push into the following instruction taking the value as an immediate
+//   - elimination of a store overwritten by the next store to the same variable
+//   - jump threading and removal of jumps to the next instruction
+//   - inversion of a conditional jump over an unconditional jump
+//   - elimination of unreachable instructions
+//
+// It does not introduce superinstructions other than the instructions with fused immediates,
+// so that an optimized program consists of the built-in instructions and can be dumped and restored.
+// It keeps track of the index of the original instruction for each optimized instruction,
+// so that errors can be reported with the original indices.
+// Addresses computed at run time, such as numbers called by callv, are not relocated.
+
+// foldableOps are the pure operations that can be evaluated at preprocessing time.
+var foldableOps = map[Mnemonic]func([]Value) (Value, error){
+	MnemonicEqual:          eq,
+	MnemonicNotEqual:       ne,
+	MnemonicGreaterThan:    gt,
+	MnemonicGreaterOrEqual: ge,
+	MnemonicLessThan:       lt,
+	MnemonicLessOrEqual:    le,
+	MnemonicAnd:            and,
+	MnemonicOr:             or,
+	MnemonicAdd:            add,
+	MnemonicSubtract:       sub,
+	MnemonicMultiply:       mul,
+	MnemonicDivide:         div,
+	MnemonicConcat:         concat,
+	MnemonicModulo:         mod,
+	MnemonicPower:          pow,
+	MnemonicIntegerDivide:  idiv,
+	MnemonicNot:            not,
+	MnemonicNeg:            neg,
+	MnemonicStringLength:   slen,
+	MnemonicUpper:          upper,
+	MnemonicLower:          lower,
+	MnemonicTrim:           trim,
+	MnemonicTypeOf:         typeof,
+	MnemonicToBoolean:      tobool,
+	MnemonicToNumber:       tonum,
+	MnemonicToString:       tostr,
+}
+
+// fusibleOps are the instructions that push their immediate as the last operand
+// or take it instead of popping an operand, mapped to their arities.
+var fusibleOps = map[Mnemonic]int{
+	MnemonicLoad:           1,
+	MnemonicLoadArgument:   1,
+	MnemonicLoadLocal:      1,
+	MnemonicLoadCaptured:   1,
+	MnemonicEqual:          2,
+	MnemonicNotEqual:       2,
+	MnemonicGreaterThan:    2,
+	MnemonicGreaterOrEqual: 2,
+	MnemonicLessThan:       2,
+	MnemonicLessOrEqual:    2,
+	MnemonicAnd:            2,
+	MnemonicOr:             2,
+	MnemonicAdd:            2,
+	MnemonicSubtract:       2,
+	MnemonicMultiply:       2,
+	MnemonicDivide:         2,
+	MnemonicConcat:         2,
+	MnemonicIndexOf:        2,
+	MnemonicSplit:          2,
+	MnemonicJoin:           2,
+	MnemonicMatch:          2,
+	MnemonicGet:            2,
+	MnemonicArrayPush:      2,
+	MnemonicHas:            2,
+	MnemonicDelete:         2,
+	MnemonicGetPointer:     2,
+	MnemonicModulo:         2,
+	MnemonicPower:          2,
+	MnemonicIntegerDivide:  2,
+	MnemonicBitwiseAnd:     2,
+	MnemonicBitwiseOr:      2,
+	MnemonicBitwiseXor:     2,
+	MnemonicShiftLeft:      2,
+	MnemonicShiftRight:     2,
+	MnemonicUnsignedShift:  2,
+}
+
+// builtinMnemonics contains the mnemonics of the built-in instructions.
+var builtinMnemonics = func() map[Mnemonic]bool {
+	ms := map[Mnemonic]bool{}
+	for _, m := range newProcessor().mnemonics() {
+		ms[m] = true
+	}
+	return ms
+}()
+
+type optimizer struct {
+	program []Instruction
+	origins []int
+
+	// modified contains the mnemonics of the instructions whose processes may differ from the built-in ones.
+	modified map[Mnemonic]stackEffect
+
+	// replaced contains the mnemonics of the built-in instructions whose results must not be folded.
+	replaced map[Mnemonic]bool
+}
+
+// optimize optimizes a preprocessed program and returns it with the original indices of its instructions.
+// The instructions bound to Go functions, whose stack effects are contained in modified, are left as they are.
+// The program is not optimized at all if it contains any other instruction whose process is not the built-in one,
+// such as an extended or overridden instruction, since it may affect the control flow.
+// The instructions whose mnemonics are contained in replaced are not folded.
+func optimize(program []Instruction, modified map[Mnemonic]stackEffect, replaced map[Mnemonic]bool) ([]Instruction, []int) {
+	origins := make([]int, len(program))
+	for idx := range origins {
+		origins[idx] = idx
+	}
+
+	for idx := range program {
+		mnemonic := program[idx].Mnemonic
+		if effect, ok := modified[mnemonic]; ok && effect == nil || !ok && !builtinMnemonics[mnemonic] {
+			return program, origins
+		}
+	}
+
+	o := &optimizer{
+		program:  append([]Instruction{}, program...),
+		origins:  origins,
+		modified: modified,
+		replaced: replaced,
+	}
+	for o.fuse() || o.simplify() || o.thread() || o.eliminate() {
+	}
+	return o.program, o.origins
+}
+
+func (o *optimizer) builtin(mnemonic Mnemonic) bool {
+	_, ok := o.modified[mnemonic]
+	return !ok
+}
+
+// address returns the position of the address in the immediates of an instruction, or -1 if there is none.
+func address(inst *Instruction) int {
+	switch inst.Mnemonic {
+	case MnemonicCall, MnemonicLoadFunction, MnemonicClosure,
+		MnemonicJump, MnemonicJumpIfTrue, MnemonicJumpIfFalse, MnemonicTry:
+		if len(inst.Immediates) > 0 {
+			return 0
+		}
+	}
+	return -1
+}
+
+// targets returns the indices of the instructions that can be executed other than by falling through.
+func (o *optimizer) targets() map[int]bool {
+	ts := map[int]bool{}
+	for idx := range o.program {
+		inst := &o.program[idx]
+		if pos := address(inst); pos >= 0 {
+			ts[ToInteger(inst.Immediates[pos])] = true
+		}
+
+		switch inst.Mnemonic {
+		case MnemonicCall, MnemonicCallValue:
+			ts[idx+1] = true
+		}
+	}
+	return ts
+}
+
+// fuse folds constants and fuses pushes into the following instructions.
+func (o *optimizer) fuse() bool {
+	ts := o.targets()
+	removed := make([]bool, len(o.program))
+	changed := false
+	for idx := 0; idx+1 < len(o.program); idx++ {
+		first := &o.program[idx]
+		second := &o.program[idx+1]
+		if first.Mnemonic != MnemonicPush || len(first.Immediates) == 0 || ts[idx+1] || !o.builtin(second.Mnemonic) {
+			continue
+		}
+
+		vs, ok := o.combine(first.Immediates, second)
+		if !ok {
+			continue
+		}
+
+		if len(vs) > 0 {
+			first.Immediates = vs
+		} else {
+			removed[idx] = true
+		}
+		changed = true
+	}
+
+	if changed {
+		o.compact(removed)
+	}
+	return changed
+}
+
+// combine combines the values pushed by a push instruction with the following instruction,
+// which is rewritten in place, and returns the values that still have to be pushed before it.
+func (o *optimizer) combine(vs []Value, inst *Instruction) ([]Value, bool) {
+	n := len(vs)
+	if inst.Mnemonic == MnemonicPush {
+		inst.Immediates = append(append([]Value{}, vs...), inst.Immediates...)
+		return nil, true
+	}
+
+	if op, ok := foldableOps[inst.Mnemonic]; ok && !o.replaced[inst.Mnemonic] {
+		args := append(append([]Value{}, vs...), inst.Immediates...)
+		arity := fusibleOps[inst.Mnemonic]
+		if arity == 0 {
+			arity = 1
+		}
+
+		if len(args) >= arity {
+			k := len(args) - arity
+			if res, err := op(append([]Value{}, args[k:]...)); err == nil && isScalar(res) {
+				inst.Mnemonic = MnemonicPush
+				inst.Immediates = append(args[:k:k], res)
+				inst.opcode = opcode(MnemonicPush)
+				return nil, true
+			}
+		}
+	}
+
+	if _, ok := fusibleOps[inst.Mnemonic]; ok && len(inst.Immediates) == 0 {
+		inst.Immediates = []Value{vs[n-1]}
+		return vs[:n-1], true
+	}
+	return nil, false
+}
+
+func isScalar(v Value) bool {
+	switch TypeOf(v) {
+	case TypeNull, TypeBoolean, TypeNumber, TypeString:
+		return true
+	default:
+		return false
+	}
+}
+
+// simplify removes the stores with two immediates followed by the stores
+// with two immediates to the same variable, which overwrite them before they can be observed.
+func (o *optimizer) simplify() bool {
+	ts := o.targets()
+	removed := make([]bool, len(o.program))
+	changed := false
+	for idx := 0; idx+1 < len(o.program); idx++ {
+		first := &o.program[idx]
+		second := &o.program[idx+1]
+		if first.Mnemonic != MnemonicStore && first.Mnemonic != MnemonicStoreLocal {
+			continue
+		}
+
+		if second.Mnemonic != first.Mnemonic || !o.builtin(first.Mnemonic) || ts[idx+1] ||
+			len(first.Immediates) != 2 || len(second.Immediates) != 2 ||
+			ToString(first.Immediates[0]) != ToString(second.Immediates[0]) {
+			continue
+		}
+
+		removed[idx] = true
+		changed = true
+	}
+
+	if changed {
+		o.compact(removed)
+	}
+	return changed
+}
+
+// thread redirects jumps to jumps, removes jumps to the next instructions,
+// and inverts conditional jumps over unconditional jumps.
+func (o *optimizer) thread() bool {
+	ts := o.targets()
+	removed := make([]bool, len(o.program))
+	changed := false
+	for idx := range o.program {
+		inst := &o.program[idx]
+		switch inst.Mnemonic {
+		case MnemonicJump, MnemonicJumpIfTrue, MnemonicJumpIfFalse:
+		default:
+			continue
+		}
+
+		addr := ToInteger(inst.Immediates[0])
+		if dest := o.destination(addr); dest != addr {
+			inst.Immediates = []Value{IntegerValue(dest)}
+			addr = dest
+			changed = true
+		}
+
+		if inst.Mnemonic == MnemonicJump && addr == idx+1 {
+			removed[idx] = true
+			changed = true
+			continue
+		}
+
+		if inst.Mnemonic != MnemonicJump && addr == idx+2 && !ts[idx+1] && !removed[idx+1] &&
+			o.program[idx+1].Mnemonic == MnemonicJump {
+			if inst.Mnemonic == MnemonicJumpIfTrue {
+				inst.Mnemonic = MnemonicJumpIfFalse
+			} else {
+				inst.Mnemonic = MnemonicJumpIfTrue
+			}
+			inst.opcode = opcode(inst.Mnemonic)
+			inst.Immediates = o.program[idx+1].Immediates
+			removed[idx+1] = true
+			changed = true
+		}
+	}
+
+	if changed {
+		o.compact(removed)
+	}
+	return changed
+}
+
+// destination follows a chain of unconditional jumps.
+// It returns the given address if the chain is an infinite loop.
+func (o *optimizer) destination(addr int) int {
+	visited := map[int]bool{}
+	dest := addr
+	for dest >= 0 && dest < len(o.program) && o.program[dest].Mnemonic == MnemonicJump {
+		if visited[dest] {
+			return addr
+		}
+		visited[dest] = true
+		dest = ToInteger(o.program[dest].Immediates[0])
+	}
+	return dest
+}
+
+// eliminate removes the instructions that are never executed.
+func (o *optimizer) eliminate() bool {
+	reachable := make([]bool, len(o.program))
+	var visit func(idx int)
+	visit = func(idx int) {
+		for idx >= 0 && idx < len(o.program) && !reachable[idx] {
+			reachable[idx] = true
+			inst := &o.program[idx]
+			if pos := address(inst); pos >= 0 {
+				visit(ToInteger(inst.Immediates[pos]))
+			}
+
+			switch inst.Mnemonic {
+			case MnemonicJump, MnemonicReturn, MnemonicThrow:
+				return
+			}
+			idx++
+		}
+	}
+	visit(0)
+
+	removed := make([]bool, len(o.program))
+	changed := false
+	for idx, ok := range reachable {
+		if !ok {
+			removed[idx] = true
+			changed = true
+		}
+	}
+
+	if changed {
+		o.compact(removed)
+	}
+	return changed
+}
+
+// compact removes instructions and relocates the addresses.
+// An address of a removed instruction is relocated to the next remaining instruction.
+func (o *optimizer) compact(removed []bool) {
+	relocated := make([]int, len(o.program)+1)
+	n := 0
+	for idx := range o.program {
+		relocated[idx] = n
+		if !removed[idx] {
+			n++
+		}
+	}
+	relocated[len(o.program)] = n
+
+	program := make([]Instruction, 0, n)
+	origins := make([]int, 0, n)
+	for idx, inst := range o.program {
+		if removed[idx] {
+			continue
+		}
+
+		if pos := address(&inst); pos >= 0 {
+			addr := ToInteger(inst.Immediates[pos])
+			if addr >= 0 && addr < len(relocated) {
+				inst.Immediates = append([]Value{}, inst.Immediates...)
+				inst.Immediates[pos] = IntegerValue(relocated[addr])
+			}
+		}
+
+		program = append(program, inst)
+		origins = append(origins, o.origins[idx])
+	}
+
+	o.program = program
+	o.origins = origins
+}
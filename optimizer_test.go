@@ -0,0 +1,246 @@
+package jsm
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptimize(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := newPreprocessor().preprocess([]Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(2)}},
+		{Mnemonic: MnemonicAdd},
+		{Mnemonic: MnemonicMultiply, Immediates: []Value{IntegerValue(4)}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{StringValue("y")}},
+		{Mnemonic: MnemonicLoadLocal},
+		{Mnemonic: MnemonicJump, Immediates: []Value{StringValue("a")}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(0)}},
+		{Label: "a", Mnemonic: MnemonicJump, Immediates: []Value{StringValue("b")}},
+		{Label: "b", Mnemonic: MnemonicJumpIfFalse, Immediates: []Value{StringValue("c")}},
+		{Mnemonic: MnemonicJump, Immediates: []Value{StringValue("d")}},
+		{Label: "c", Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		{Label: "d", Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(2)}},
+	})
+	assert.NoError(err)
+
+	p, origins := optimize(p, nil, nil)
+	data, err := json.Marshal(p)
+	assert.NoError(err)
+	assert.JSONEq(`[
+		{"mnemonic": "push", "immediates": [12]},
+		{"mnemonic": "ldl", "immediates": ["y"]},
+		{"mnemonic": "jt", "immediates": [4]},
+		{"mnemonic": "ret", "immediates": [1]},
+		{"mnemonic": "ret", "immediates": [2]}
+	]`, string(data))
+	assert.Equal([]int{4, 5, 9, 11, 12}, origins)
+
+	for _, inst := range p {
+		assert.Equal(opcode(inst.Mnemonic), inst.opcode)
+	}
+}
+
+func TestOptimizeModified(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := newPreprocessor().preprocess([]Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1), IntegerValue(2)}},
+		{Mnemonic: MnemonicAdd},
+		{Mnemonic: MnemonicJump, Immediates: []Value{IntegerValue(3)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	})
+	assert.NoError(err)
+
+	q, origins := optimize(p, map[Mnemonic]stackEffect{MnemonicAdd: fixedEffect(2, 1)}, nil)
+	assert.Len(q, 3)
+	assert.Equal(Mnemonic(MnemonicPush), q[0].Mnemonic)
+	assert.Equal([]Value{IntegerValue(1), IntegerValue(2)}, q[0].Immediates)
+	assert.Equal(Mnemonic(MnemonicAdd), q[1].Mnemonic)
+	assert.Empty(q[1].Immediates)
+	assert.Equal([]int{0, 1, 3}, origins)
+
+	q, origins = optimize(p, map[Mnemonic]stackEffect{MnemonicAdd: nil}, nil)
+	assert.Equal(p, q)
+	assert.Equal([]int{0, 1, 2, 3}, origins)
+
+	q, origins = optimize(p, map[Mnemonic]stackEffect{MnemonicJump: nil}, nil)
+	assert.Equal(p, q)
+	assert.Equal([]int{0, 1, 2, 3}, origins)
+}
+
+func TestOptimizeExtended(t *testing.T) {
+	assert := assert.New(t)
+
+	goTo := func(ctx context.Context, imms []Value) error {
+		GetProgramCounter(ctx).SetIndex(ToInteger(imms[0]))
+		return nil
+	}
+	resolve := func(ctx context.Context, imms []Value) ([]Value, error) {
+		return []Value{IntegerValue(GetLabels(ctx)[ToString(imms[0])])}, nil
+	}
+
+	p := []Instruction{
+		{Mnemonic: MnemonicJump, Immediates: []Value{StringValue("a")}},
+		{Label: "a", Mnemonic: "goto", Immediates: []Value{StringValue("b")}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		{Label: "b", Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(2)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	}
+
+	for _, opts := range [][]Option{nil, {WithOptimization()}} {
+		m := NewMachine(opts...)
+		assert.NoError(m.Extend("goto", goTo, resolve))
+
+		res, err := m.Run(p, nil)
+		assert.NoError(err)
+		assert.Equal([]Value{IntegerValue(2)}, res)
+	}
+}
+
+func TestOptimizeDeadCode(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := newPreprocessor().preprocess([]Instruction{
+		{Mnemonic: MnemonicTry, Immediates: []Value{StringValue("catch")}},
+		{Mnemonic: MnemonicLoadFunction, Immediates: []Value{StringValue("f")}},
+		{Mnemonic: MnemonicCallValue},
+		{Mnemonic: MnemonicThrow},
+		{Mnemonic: MnemonicNop},
+		{Label: "catch", Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicNop},
+		{Label: "f", Mnemonic: MnemonicReturn},
+	})
+	assert.NoError(err)
+
+	p, origins := optimize(p, nil, nil)
+	assert.Equal([]int{0, 1, 2, 3, 5, 7}, origins)
+	assert.Equal([]Value{IntegerValue(4)}, p[0].Immediates)
+	assert.Equal([]Value{IntegerValue(5)}, p[1].Immediates)
+}
+
+func TestOptimizeLocalStore(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := newPreprocessor().preprocess([]Instruction{
+		{Mnemonic: MnemonicStoreLocal, Immediates: []Value{StringValue("x"), IntegerValue(1)}},
+		{Mnemonic: MnemonicStoreLocal, Immediates: []Value{StringValue("x"), IntegerValue(2)}},
+		{Mnemonic: MnemonicStoreLocal, Immediates: []Value{StringValue("y"), IntegerValue(3)}},
+		{Label: "a", Mnemonic: MnemonicStoreLocal, Immediates: []Value{StringValue("y"), IntegerValue(4)}},
+		{Mnemonic: MnemonicStoreLocal, Immediates: []Value{StringValue("y"), IntegerValue(5)}},
+		{Mnemonic: MnemonicStore, Immediates: []Value{StringValue("y"), IntegerValue(6)}},
+		{Mnemonic: MnemonicLoadLocal, Immediates: []Value{StringValue("y")}},
+		{Mnemonic: MnemonicStoreLocal, Immediates: []Value{StringValue("y")}},
+		{Mnemonic: MnemonicLoadLocal, Immediates: []Value{StringValue("y")}},
+		{Mnemonic: MnemonicJumpIfTrue, Immediates: []Value{StringValue("a")}},
+		{Mnemonic: MnemonicReturn},
+	})
+	assert.NoError(err)
+
+	q, origins := optimize(p, nil, nil)
+	assert.Equal([]int{1, 2, 4, 5, 6, 7, 8, 9, 10}, origins)
+	assert.Equal([]Value{IntegerValue(2)}, q[7].Immediates)
+
+	q, origins = optimize(p, map[Mnemonic]stackEffect{MnemonicStoreLocal: nil}, nil)
+	assert.Equal(p, q)
+	assert.Equal([]int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, origins)
+}
+
+func TestMachineLocalStore(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{StringValue("x")}},
+		{Mnemonic: MnemonicStoreLocal, Immediates: []Value{StringValue("a")}},
+		{Mnemonic: MnemonicLoadLocal, Immediates: []Value{StringValue("x")}},
+		{Mnemonic: MnemonicStoreLocal, Immediates: []Value{StringValue("x")}},
+		{Mnemonic: MnemonicStore, Immediates: []Value{StringValue("y"), IntegerValue(2)}},
+		{Mnemonic: MnemonicStore, Immediates: []Value{StringValue("y"), IntegerValue(3)}},
+		{Mnemonic: MnemonicLoadLocal, Immediates: []Value{StringValue("x")}},
+		{Mnemonic: MnemonicLoadLocal, Immediates: []Value{StringValue("a")}},
+		{Mnemonic: MnemonicLoad, Immediates: []Value{StringValue("y")}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(3)}},
+	}
+
+	m1 := NewMachine()
+	res, err := m1.Run(p, nil)
+	assert.NoError(err)
+	assert.Equal([]Value{StringValue("a"), StringValue("x"), IntegerValue(3)}, res)
+
+	m2 := NewMachine(WithOptimization())
+	res, err = m2.Run(p, nil)
+	assert.NoError(err)
+	assert.Equal([]Value{StringValue("a"), StringValue("x"), IntegerValue(3)}, res)
+	assert.Less(m2.ConsumedGas(), m1.ConsumedGas())
+}
+
+func TestMachineOptimization(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		example string
+		args    []Value
+	}{
+		{"fibonacci.json", []Value{IntegerValue(10)}},
+		{"sum_of_series.json", []Value{IntegerValue(100)}},
+		{"map.json", []Value{ArrayValue([]Value{IntegerValue(1), IntegerValue(2)})}},
+	}
+
+	for _, test := range tests {
+		p := loadExample(t, test.example)
+
+		m1 := NewMachine()
+		res1, err1 := m1.Run(p, test.args)
+
+		m2 := NewMachine(WithOptimization())
+		res2, err2 := m2.Run(p, test.args)
+
+		assert.NoError(err1)
+		assert.NoError(err2)
+		assert.Equal(res1, res2, test.example)
+		assert.True(m2.ConsumedGas() <= m1.ConsumedGas(), test.example)
+	}
+
+	m := NewMachine(WithOptimization())
+	_, err := m.Run([]Instruction{
+		{Mnemonic: MnemonicJump, Immediates: []Value{StringValue("f")}},
+		{Mnemonic: MnemonicNop},
+		{Label: "f", Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicDivide, Comment: "1 / 0"},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	}, nil)
+	assert.EqualError(err, "div at 4 (f): divide by zero")
+
+	var re *RuntimeError
+	assert.ErrorAs(err, &re)
+	assert.Equal("1 / 0", re.Comment)
+}
+
+func TestRestoreOptimized(t *testing.T) {
+	assert := assert.New(t)
+
+	m := NewMachine(WithOptimization()).(*machine)
+	assert.NoError(m.load([]Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(2)}},
+		{Mnemonic: MnemonicAdd},
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicDivide},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	}, nil, true))
+	assert.Len(m.Program, 3)
+	assert.NoError(m.step())
+
+	data, err := m.Dump()
+	assert.NoError(err)
+
+	m2 := newMachine()
+	assert.NoError(m2.Restore(data))
+	assert.EqualError(m2.step(), "div at 4: divide by zero")
+}
@@ -0,0 +1,148 @@
+package jsm
+
+import "io"
+
+// Option configures a Machine.
+type Option func(m *machine)
+
+// WithGasLimit limits the amount of gas that a single run can consume.
+// A limit of zero or less means that the gas is unlimited.
+func WithGasLimit(limit int) Option {
+	return func(m *machine) {
+		m.gas.limit = limit
+	}
+}
+
+// WithGasCost sets the amount of gas consumed by each execution of
+// the instructions with the specified mnemonic.
+// Instructions whose cost is not set consume one unit of gas.
+// A negative cost is treated as zero.
+func WithGasCost(mnemonic Mnemonic, cost int) Option {
+	return func(m *machine) {
+		if cost < 0 {
+			cost = 0
+		}
+		m.gas.costs[mnemonic] = cost
+	}
+}
+
+// WithMaxCallDepth limits the depth of the call stack.
+func WithMaxCallDepth(depth int) Option {
+	return func(m *machine) {
+		m.limits.maxCallDepth = depth
+	}
+}
+
+// WithMaxOperands limits the number of values on the operand stack of each frame.
+func WithMaxOperands(n int) Option {
+	return func(m *machine) {
+		m.limits.maxOperands = n
+	}
+}
+
+// WithMaxHeapKeys limits the total number of keys
+// stored in the global heap and all local heaps.
+func WithMaxHeapKeys(n int) Option {
+	return func(m *machine) {
+		m.limits.maxHeapKeys = n
+	}
+}
+
+// WithMaxHeapBytes limits the approximate total number of bytes
+// used by the global heap and all local heaps.
+func WithMaxHeapBytes(n int) Option {
+	return func(m *machine) {
+		m.limits.maxHeapBytes = n
+	}
+}
+
+// WithInt64 makes the bitwise instructions operate on 64-bit integers
+// instead of 32-bit integers as in JavaScript.
+// Integers that cannot be represented by int are held as int64 or uint64,
+// and add, sub and mul are exact for them.
+func WithInt64() Option {
+	return func(m *machine) {
+		m.replace(MnemonicBitwiseAnd, binaryOp(band64))
+		m.replace(MnemonicBitwiseOr, binaryOp(bor64))
+		m.replace(MnemonicBitwiseXor, binaryOp(bxor64))
+		m.replace(MnemonicBitwiseNot, unaryOp(bnot64))
+		m.replace(MnemonicShiftLeft, binaryOp(shl64))
+		m.replace(MnemonicShiftRight, binaryOp(shr64))
+		m.replace(MnemonicUnsignedShift, binaryOp(ushr64))
+		m.replace(MnemonicAdd, binaryOp(add64))
+		m.replace(MnemonicSubtract, binaryOp(sub64))
+		m.replace(MnemonicMultiply, binaryOp(mul64))
+	}
+}
+
+// WithVerification verifies the operand stack of programs before running them, which is the default.
+// Programs are rejected if an instruction may pop more operands than the stack has,
+// or if the returns of a function return different numbers of values.
+// Labels and addresses are verified regardless of this option.
+func WithVerification() Option {
+	return func(m *machine) {
+		if m.verification < verifyStack {
+			m.verification = verifyStack
+		}
+	}
+}
+
+// WithStrictVerification is like WithVerification, but also rejects programs
+// in which the depth of the operand stack at an instruction differs between paths,
+// such as a loop that pushes a value in each iteration.
+func WithStrictVerification() Option {
+	return func(m *machine) {
+		m.verification = verifyStrict
+	}
+}
+
+// WithoutVerification disables the verification of the operand stack,
+// so that the programs that may underflow the stack fail only when they do.
+// Labels and addresses are still verified.
+func WithoutVerification() Option {
+	return func(m *machine) {
+		m.verification = verifyAddresses
+	}
+}
+
+// WithOptimization optimizes programs before running them.
+// The optimization folds constants, fuses pushes into the following instructions,
+// removes stores overwritten by the next stores, threads jumps and eliminates unreachable instructions.
+// Errors still report the indices of the original instructions,
+// but the hooks observe the optimized instructions, which also determine the gas consumption.
+// Programs containing extended or overridden instructions are run as they are,
+// and programs that call numeric addresses computed at run time must not be optimized.
+// Debuggers always run programs without optimization.
+func WithOptimization() Option {
+	return func(m *machine) {
+		m.optimization = true
+	}
+}
+
+// WithCompilation runs programs by compiling them into Go closures
+// instead of interpreting their instructions one by one.
+// The compiled programs produce the same results and errors as the interpreted ones.
+// Unless hooks, a gas limit or limits are set,
+// the closures run one after another without the checks between instructions.
+func WithCompilation() Option {
+	return func(m *machine) {
+		m.compilation = true
+	}
+}
+
+// WithTrace writes a line to the specified writer before each instruction is executed.
+// The line consists of the depth of the call stack, the index of the instruction,
+// the instruction itself and the current operand stack, separated by tabs.
+func WithTrace(w io.Writer) Option {
+	return func(m *machine) {
+		m.hooks = append(m.hooks, &tracer{w: w})
+	}
+}
+
+// WithHook adds a Hook that observes the execution of programs.
+// Hooks are called in the order in which they are added.
+func WithHook(h Hook) Option {
+	return func(m *machine) {
+		m.hooks = append(m.hooks, h)
+	}
+}
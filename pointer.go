@@ -0,0 +1,187 @@
+package jsm
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// parsePointer splits a JSON Pointer defined in RFC 6901 into its reference tokens.
+func parsePointer(ptr string) ([]string, error) {
+	if ptr == "" {
+		return nil, nil
+	}
+
+	if ptr[0] != '/' {
+		return nil, errors.New("invalid pointer")
+	}
+
+	tokens := strings.Split(ptr[1:], "/")
+	for i, token := range tokens {
+		for j := 0; j < len(token); j++ {
+			if token[j] != '~' {
+				continue
+			}
+
+			if j+1 == len(token) || (token[j+1] != '0' && token[j+1] != '1') {
+				return nil, errors.New("invalid pointer")
+			}
+			j++
+		}
+		tokens[i] = pointerUnescaper.Replace(token)
+	}
+	return tokens, nil
+}
+
+var pointerUnescaper = strings.NewReplacer("~1", "/", "~0", "~")
+
+// arrayIndex converts a reference token to an index of an array of the given length.
+// The token "-" refers to the element after the last one.
+func arrayIndex(token string, l int) (int, error) {
+	if token == "-" {
+		return l, nil
+	}
+
+	if token == "" || (token[0] == '0' && len(token) > 1) {
+		return -1, errors.New("invalid index")
+	}
+
+	idx, err := strconv.Atoi(token)
+	if err != nil || idx < 0 {
+		return -1, errors.New("invalid index")
+	}
+	return idx, nil
+}
+
+// getp returns the value referenced by a JSON Pointer, or null if there is no such value.
+func getp(vs []Value) (Value, error) {
+	tokens, err := parsePointer(ToString(vs[1]))
+	if err != nil {
+		return NullValue(), err
+	}
+
+	v := vs[0]
+	for _, token := range tokens {
+		if a, ok := arrayOf(v); ok {
+			idx, err := arrayIndex(token, len(a))
+			if err != nil {
+				return NullValue(), err
+			}
+
+			if idx >= len(a) {
+				return NullValue(), nil
+			}
+			v = a[idx]
+		} else if o, ok := objectOf(v); ok {
+			v = o[token]
+		} else {
+			return NullValue(), nil
+		}
+	}
+	return v, nil
+}
+
+// setp returns a copy of a value in which the value referenced by a JSON Pointer is replaced.
+// Only the arrays and objects on the path are copied, and the others are shared.
+func setp(vs []Value) (Value, error) {
+	tokens, err := parsePointer(ToString(vs[1]))
+	if err != nil {
+		return NullValue(), err
+	}
+	return setPointer(vs[0], tokens, vs[2])
+}
+
+// setpOp processes setp, whose immediates are the pointer and the value to write in this order.
+// If only the pointer is given, the document and the value are taken from the operand stack.
+func setpOp(ctx context.Context, imms []Value) error {
+	if len(imms) != 1 {
+		return naryOp(setp, 3)(ctx, imms)
+	}
+
+	ptr := imms[0]
+	if err := doOp(ctx, func(vs []Value) (Value, error) {
+		return setp([]Value{vs[0], ptr, vs[1]})
+	}, 2); err != nil {
+		return err
+	}
+
+	GetProgramCounter(ctx).Increment()
+	return nil
+}
+
+func setPointer(v Value, tokens []string, x Value) (Value, error) {
+	if len(tokens) == 0 {
+		return x, nil
+	}
+
+	if a, ok := arrayOf(v); ok {
+		idx, err := arrayIndex(tokens[0], len(a))
+		if err != nil {
+			return NullValue(), err
+		}
+
+		if idx > len(a) {
+			return NullValue(), errors.New("index out of range")
+		}
+
+		var elem Value
+		if idx < len(a) {
+			elem = a[idx]
+		}
+
+		elem, err = setPointer(elem, tokens[1:], x)
+		if err != nil {
+			return NullValue(), err
+		}
+
+		a = copyArray(a)
+		if idx == len(a) {
+			a = append(a, elem)
+		} else {
+			a[idx] = elem
+		}
+		return ArrayValue(a), nil
+	}
+
+	if o, ok := objectOf(v); ok {
+		elem, err := setPointer(o[tokens[0]], tokens[1:], x)
+		if err != nil {
+			return NullValue(), err
+		}
+
+		o = copyObject(o)
+		o[tokens[0]] = elem
+		return ObjectValue(o), nil
+	}
+
+	return NullValue(), errors.New("path not found")
+}
+
+func immediatesOfGetPointer(ctx context.Context, imms []Value) ([]Value, error) {
+	vs, err := atMostOneString(ctx, imms)
+	if err != nil || len(vs) == 0 {
+		return vs, err
+	}
+
+	if _, err := parsePointer(ToString(vs[0])); err != nil {
+		return nil, preprocessingError(ctx, imms, "invalid pointer")
+	}
+	return vs, nil
+}
+
+func immediatesOfSetPointer(ctx context.Context, imms []Value) ([]Value, error) {
+	switch len(imms) {
+	case 0:
+		return nil, nil
+	case 1, 2:
+		ptr := ToString(imms[0])
+		if _, err := parsePointer(ptr); err != nil {
+			return nil, preprocessingError(ctx, imms, "invalid pointer")
+		}
+		return append([]Value{StringValue(ptr)}, imms[1:]...), nil
+	default:
+		return nil, preprocessingError(ctx, imms, "too many immediates")
+	}
+}
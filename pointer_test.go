@@ -0,0 +1,154 @@
+package jsm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePointer(t *testing.T) {
+	assert := assert.New(t)
+
+	tokens, err := parsePointer("")
+	assert.NoError(err)
+	assert.Empty(tokens)
+
+	tokens, err = parsePointer("/")
+	assert.NoError(err)
+	assert.Equal([]string{""}, tokens)
+
+	tokens, err = parsePointer("/a~1b/m~0n/0")
+	assert.NoError(err)
+	assert.Equal([]string{"a/b", "m~n", "0"}, tokens)
+
+	tokens, err = parsePointer("/~01")
+	assert.NoError(err)
+	assert.Equal([]string{"~1"}, tokens)
+
+	for _, ptr := range []string{"a", "/~", "/~2", "/a~"} {
+		_, err = parsePointer(ptr)
+		assert.EqualError(err, "invalid pointer", ptr)
+	}
+}
+
+func TestGetPointer(t *testing.T) {
+	assert := assert.New(t)
+
+	doc := ObjectValue(map[string]Value{
+		"foo": ArrayValue([]Value{StringValue("bar"), StringValue("baz")}),
+		"":    IntegerValue(0),
+		"a/b": IntegerValue(1),
+		"m~n": IntegerValue(8),
+		"x":   map[string]int{"y": 2},
+	})
+
+	tests := []struct {
+		ptr string
+		res Value
+	}{
+		{"", doc},
+		{"/foo/0", StringValue("bar")},
+		{"/foo/2", NullValue()},
+		{"/foo/-", NullValue()},
+		{"/", IntegerValue(0)},
+		{"/a~1b", IntegerValue(1)},
+		{"/m~0n", IntegerValue(8)},
+		{"/x/y", 2},
+		{"/none/y", NullValue()},
+	}
+
+	for _, test := range tests {
+		res, err := getp([]Value{doc, StringValue(test.ptr)})
+		assert.NoError(err)
+		assert.Equal(test.res, res, test.ptr)
+	}
+
+	_, err := getp([]Value{doc, StringValue("/foo/01")})
+	assert.EqualError(err, "invalid index")
+}
+
+func TestSetPointer(t *testing.T) {
+	assert := assert.New(t)
+
+	inner := ArrayValue([]Value{IntegerValue(1)})
+	doc := ObjectValue(map[string]Value{
+		"a": ArrayValue([]Value{ObjectValue(map[string]Value{"b": IntegerValue(0)})}),
+		"c": inner,
+	})
+
+	res, err := setp([]Value{doc, StringValue("/a/0/b"), IntegerValue(1)})
+	assert.NoError(err)
+	assert.Equal(ObjectValue(map[string]Value{
+		"a": ArrayValue([]Value{ObjectValue(map[string]Value{"b": IntegerValue(1)})}),
+		"c": inner,
+	}), res)
+	assert.Equal(ObjectValue(map[string]Value{
+		"a": ArrayValue([]Value{ObjectValue(map[string]Value{"b": IntegerValue(0)})}),
+		"c": inner,
+	}), doc)
+
+	res, err = setp([]Value{doc, StringValue("/c/-"), IntegerValue(2)})
+	assert.NoError(err)
+	assert.Equal(ArrayValue([]Value{IntegerValue(1), IntegerValue(2)}), res.(map[string]Value)["c"])
+	assert.Equal(ArrayValue([]Value{IntegerValue(1)}), inner)
+
+	res, err = setp([]Value{doc, StringValue(""), IntegerValue(3)})
+	assert.NoError(err)
+	assert.Equal(IntegerValue(3), res)
+
+	_, err = setp([]Value{doc, StringValue("/c/2"), IntegerValue(2)})
+	assert.EqualError(err, "index out of range")
+
+	_, err = setp([]Value{doc, StringValue("/d/e"), IntegerValue(2)})
+	assert.EqualError(err, "path not found")
+}
+
+func TestPointerInstructions(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicSetPointer, Immediates: []Value{StringValue("/user/name"), StringValue("bob")}},
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{StringValue("/user/name")}},
+		{Mnemonic: MnemonicGetPointer},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(2)}},
+	}
+
+	args := []Value{ObjectValue(map[string]Value{
+		"user": ObjectValue(map[string]Value{"name": StringValue("alice")}),
+	})}
+
+	m := NewMachine()
+	res, err := m.Run(p, args)
+	assert.NoError(err)
+	assert.Equal([]Value{
+		ObjectValue(map[string]Value{
+			"user": ObjectValue(map[string]Value{"name": StringValue("bob")}),
+		}),
+		StringValue("alice"),
+	}, res)
+
+	res, err = m.Run([]Instruction{
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{StringValue("carol")}},
+		{Mnemonic: MnemonicSetPointer, Immediates: []Value{StringValue("/user/name")}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	}, args)
+	assert.NoError(err)
+	assert.Equal([]Value{
+		ObjectValue(map[string]Value{
+			"user": ObjectValue(map[string]Value{"name": StringValue("carol")}),
+		}),
+	}, res)
+
+	_, err = m.Run([]Instruction{
+		{Mnemonic: MnemonicGetPointer, Immediates: []Value{StringValue("user")}},
+	}, nil)
+	assert.EqualError(err, `invalid pointer: {"mnemonic":"getp","immediates":["user"]}`)
+
+	_, err = m.Run([]Instruction{
+		{Mnemonic: MnemonicSetPointer, Immediates: []Value{StringValue("user")}},
+	}, nil)
+	assert.EqualError(err, `invalid pointer: {"mnemonic":"setp","immediates":["user"]}`)
+}
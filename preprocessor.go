@@ -12,6 +12,22 @@ type Preprocess func(ctx context.Context, imms []Value) ([]Value, error)
 
 type preprocessor map[Mnemonic]Preprocess
 
+// Prepare resolves the labels of a program and normalizes the immediates of its instructions
+// in the same way as a machine created by NewMachine does before running it,
+// and verifies the stack effects of the instructions.
+// The preprocessed instructions refer to their addresses by indices instead of labels.
+func Prepare(program []Instruction) ([]Instruction, error) {
+	p, err := newPreprocessor().preprocess(program)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := verify(p, nil, verifyStack); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
 func newPreprocessor() *preprocessor {
 	return &preprocessor{
 		MnemonicPush:           noPreprocessing,
@@ -22,6 +38,10 @@ func newPreprocessor() *preprocessor {
 		MnemonicStore:          immediatesOfStore,
 		MnemonicStoreLocal:     immediatesOfStore,
 		MnemonicCall:           immediatesOfCall,
+		MnemonicCallValue:      atMostOneInteger,
+		MnemonicLoadFunction:   oneAddress,
+		MnemonicClosure:        immediatesOfCall,
+		MnemonicLoadCaptured:   atMostOneImmediate,
 		MnemonicReturn:         atMostOneInteger,
 		MnemonicJump:           oneAddress,
 		MnemonicJumpIfTrue:     oneAddress,
@@ -40,6 +60,36 @@ func newPreprocessor() *preprocessor {
 		MnemonicIncrementLocal: atMostOneString,
 		MnemonicDecrement:      atMostOneString,
 		MnemonicDecrementLocal: atMostOneString,
+		MnemonicTry:            oneAddress,
+		MnemonicThrow:          atMostOneImmediate,
+		MnemonicConcat:         atMostOneString,
+		MnemonicSlice:          immediatesOfSlice,
+		MnemonicIndexOf:        atMostOneString,
+		MnemonicSplit:          atMostOneString,
+		MnemonicJoin:           atMostOneString,
+		MnemonicReplace:        atMostTwoStrings,
+		MnemonicMatch:          immediatesOfMatch,
+		MnemonicArray:          atMostOneInteger,
+		MnemonicObject:         atMostOneInteger,
+		MnemonicGet:            atMostOneImmediate,
+		MnemonicSet:            atMostTwoImmediates,
+		MnemonicArrayPush:      atMostOneImmediate,
+		MnemonicHas:            atMostOneImmediate,
+		MnemonicDelete:         atMostOneImmediate,
+		MnemonicSpread:         oneCount,
+		MnemonicGetPointer:     immediatesOfGetPointer,
+		MnemonicSetPointer:     immediatesOfSetPointer,
+		MnemonicModulo:         atMostOneNumber,
+		MnemonicPower:          atMostOneNumber,
+		MnemonicIntegerDivide:  atMostOneNumber,
+		MnemonicMin:            atMostOneInteger,
+		MnemonicMax:            atMostOneInteger,
+		MnemonicBitwiseAnd:     atMostOneImmediate,
+		MnemonicBitwiseOr:      atMostOneImmediate,
+		MnemonicBitwiseXor:     atMostOneImmediate,
+		MnemonicShiftLeft:      atMostOneImmediate,
+		MnemonicShiftRight:     atMostOneImmediate,
+		MnemonicUnsignedShift:  atMostOneImmediate,
 	}
 }
 
@@ -60,6 +110,16 @@ func (pp preprocessor) extend(mnemonic Mnemonic, preprocess Preprocess) error {
 	return nil
 }
 
+// override replaces the preprocess of a mnemonic.
+// A nil preprocess means that the instruction takes no immediates.
+func (pp preprocessor) override(mnemonic Mnemonic, preprocess Preprocess) {
+	if preprocess == nil {
+		delete(pp, mnemonic)
+		return
+	}
+	pp[mnemonic] = preprocess
+}
+
 func (pp preprocessor) preprocess(program []Instruction) ([]Instruction, error) {
 	if program == nil {
 		return nil, errors.New("no program")
@@ -69,6 +129,9 @@ func (pp preprocessor) preprocess(program []Instruction) ([]Instruction, error)
 	labels := GetLabels(ctx)
 	for idx, inst := range program {
 		if inst.Label != "" {
+			if _, ok := labels[inst.Label]; ok {
+				return nil, errors.Errorf("duplicate label: %s", inst.Label)
+			}
 			labels[inst.Label] = idx
 		}
 	}
@@ -157,7 +220,11 @@ func oneAddress(ctx context.Context, imms []Value) ([]Value, error) {
 	case 0:
 		return nil, preprocessingError(ctx, imms, "no immediate")
 	case 1:
-		return []Value{toAddress(ctx, imms[0])}, nil
+		addr, err := toAddress(ctx, imms)
+		if err != nil {
+			return nil, err
+		}
+		return []Value{addr}, nil
 	default:
 		return nil, preprocessingError(ctx, imms, "too many immediates")
 	}
@@ -168,9 +235,17 @@ func immediatesOfCall(ctx context.Context, imms []Value) ([]Value, error) {
 	case 0:
 		return nil, preprocessingError(ctx, imms, "no immediate")
 	case 1:
-		return []Value{toAddress(ctx, imms[0])}, nil
+		addr, err := toAddress(ctx, imms)
+		if err != nil {
+			return nil, err
+		}
+		return []Value{addr}, nil
 	case 2:
-		return []Value{toAddress(ctx, imms[0]), IntegerValue(ToInteger(imms[1]))}, nil
+		addr, err := toAddress(ctx, imms)
+		if err != nil {
+			return nil, err
+		}
+		return []Value{addr, IntegerValue(ToInteger(imms[1]))}, nil
 	default:
 		return nil, preprocessingError(ctx, imms, "too many immediates")
 	}
@@ -189,12 +264,16 @@ func immediatesOfStore(ctx context.Context, imms []Value) ([]Value, error) {
 	}
 }
 
-func toAddress(ctx context.Context, v Value) Value {
-	switch TypeOf(v) {
+func toAddress(ctx context.Context, imms []Value) (Value, error) {
+	switch TypeOf(imms[0]) {
 	case TypeString:
-		return IntegerValue(GetLabels(ctx)[ToString(v)])
+		addr, ok := GetLabels(ctx)[ToString(imms[0])]
+		if !ok {
+			return NullValue(), preprocessingError(ctx, imms, "undefined label")
+		}
+		return IntegerValue(addr), nil
 	default:
-		return IntegerValue(ToInteger(v))
+		return IntegerValue(ToInteger(imms[0])), nil
 	}
 }
 
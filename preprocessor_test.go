@@ -2,6 +2,7 @@ package jsm
 
 import (
 	"encoding/json"
+	"fmt"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -73,3 +74,90 @@ func TestPreprocess(t *testing.T) {
 	assert.NoError(err)
 	assert.Equal(before, after)
 }
+
+func TestPreprocessLabels(t *testing.T) {
+	assert := assert.New(t)
+
+	_, err := newPreprocessor().preprocess([]Instruction{
+		{Label: "abc", Mnemonic: MnemonicNop},
+		{Label: "abc", Mnemonic: MnemonicNop},
+	})
+	assert.EqualError(err, "duplicate label: abc")
+
+	_, err = newPreprocessor().preprocess([]Instruction{
+		{Mnemonic: MnemonicJump, Immediates: []Value{StringValue("xyz")}},
+	})
+	assert.EqualError(err, "undefined label: {\"mnemonic\":\"jmp\",\"immediates\":[\"xyz\"]}")
+
+	_, err = newPreprocessor().preprocess([]Instruction{
+		{Mnemonic: MnemonicCall, Immediates: []Value{StringValue("xyz"), IntegerValue(1)}},
+	})
+	assert.Error(err)
+}
+
+func TestPrepare(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := Prepare([]Instruction{
+		{Mnemonic: MnemonicJump, Immediates: []Value{StringValue("end")}},
+		{Label: "end", Mnemonic: MnemonicLoadArgument, Immediates: []Value{NumberValue(0.5)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	})
+	assert.NoError(err)
+	assert.Equal([]Value{IntegerValue(1)}, p[0].Immediates)
+	assert.Equal([]Value{IntegerValue(0)}, p[1].Immediates)
+
+	_, err = Prepare([]Instruction{{Mnemonic: MnemonicAdd}})
+	assert.EqualError(err, "stack underflow at 0: add needs 2 operands but has 0")
+}
+
+func BenchmarkPreprocess(b *testing.B) {
+	b.ReportAllocs()
+
+	var p []Instruction
+	for i := 0; i < 2500; i++ {
+		p = append(p,
+			Instruction{Label: fmt.Sprintf("l%d", i), Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(i)}},
+			Instruction{Mnemonic: MnemonicJumpIfTrue, Immediates: []Value{StringValue(fmt.Sprintf("l%d", i+1))}},
+			Instruction{Mnemonic: MnemonicCall, Immediates: []Value{StringValue("l0"), IntegerValue(0)}},
+			Instruction{Mnemonic: MnemonicPop},
+		)
+	}
+	p = append(p, Instruction{Label: "l2500", Mnemonic: MnemonicReturn})
+
+	pp := newPreprocessor()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := pp.preprocess(p); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkLoad(b *testing.B) {
+	b.ReportAllocs()
+
+	var p []Instruction
+	for i := 0; i < 2500; i++ {
+		p = append(p,
+			Instruction{Label: fmt.Sprintf("l%d", i), Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(i)}},
+			Instruction{Mnemonic: MnemonicJumpIfFalse, Immediates: []Value{StringValue(fmt.Sprintf("l%d", i+1))}},
+			Instruction{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(i)}},
+			Instruction{Mnemonic: MnemonicCall, Immediates: []Value{StringValue("f"), IntegerValue(1)}},
+			Instruction{Mnemonic: MnemonicPop},
+		)
+	}
+	p = append(p,
+		Instruction{Label: "l2500", Mnemonic: MnemonicReturn},
+		Instruction{Label: "f", Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		Instruction{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	)
+
+	m := NewMachine(WithStrictVerification()).(*machine)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if err := m.load(p, nil, false); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -2,6 +2,8 @@ package jsm
 
 import (
 	"context"
+	"math"
+	"sort"
 
 	"github.com/pkg/errors"
 )
@@ -21,34 +23,96 @@ func newProcessor() *processor {
 	extend(MnemonicNop, nop)
 	extend(MnemonicPush, push)
 	extend(MnemonicPop, pop)
-	extend(MnemonicLoad, loadOp(ld))
+	extend(MnemonicLoad, loadSlotOp(ld))
 	extend(MnemonicLoadArgument, loadOp(lda))
-	extend(MnemonicLoadLocal, loadOp(ldl))
+	extend(MnemonicLoadLocal, loadSlotOp(ldl))
 	extend(MnemonicStore, storeOp(st))
 	extend(MnemonicStoreLocal, storeOp(stl))
 	extend(MnemonicCall, call)
+	extend(MnemonicCallValue, callv)
+	extend(MnemonicLoadFunction, ldf)
+	extend(MnemonicClosure, closure)
+	extend(MnemonicLoadCaptured, loadOp(ldc))
 	extend(MnemonicReturn, ret)
 	extend(MnemonicJump, jmp)
 	extend(MnemonicJumpIfTrue, jt)
 	extend(MnemonicJumpIfFalse, jf)
-	extend(MnemonicEqual, binaryOp(eq))
-	extend(MnemonicNotEqual, binaryOp(ne))
-	extend(MnemonicGreaterThan, binaryOp(gt))
-	extend(MnemonicGreaterOrEqual, binaryOp(ge))
-	extend(MnemonicLessThan, binaryOp(lt))
-	extend(MnemonicLessOrEqual, binaryOp(le))
+	extend(MnemonicEqual, numberOp(eq, eqNumbers))
+	extend(MnemonicNotEqual, numberOp(ne, neNumbers))
+	extend(MnemonicGreaterThan, numberOp(gt, gtNumbers))
+	extend(MnemonicGreaterOrEqual, numberOp(ge, geNumbers))
+	extend(MnemonicLessThan, numberOp(lt, ltNumbers))
+	extend(MnemonicLessOrEqual, numberOp(le, leNumbers))
 	extend(MnemonicNot, unaryOp(not))
 	extend(MnemonicAnd, binaryOp(and))
 	extend(MnemonicOr, binaryOp(or))
 	extend(MnemonicNeg, unaryOp(neg))
-	extend(MnemonicAdd, binaryOp(add))
-	extend(MnemonicSubtract, binaryOp(sub))
-	extend(MnemonicMultiply, binaryOp(mul))
+	extend(MnemonicAdd, numberOp(add, addNumbers))
+	extend(MnemonicSubtract, numberOp(sub, subNumbers))
+	extend(MnemonicMultiply, numberOp(mul, mulNumbers))
 	extend(MnemonicDivide, binaryOp(div))
 	extend(MnemonicIncrement, loadStoreOp(inc))
 	extend(MnemonicIncrementLocal, loadStoreOp(incl))
 	extend(MnemonicDecrement, loadStoreOp(dec))
 	extend(MnemonicDecrementLocal, loadStoreOp(decl))
+	extend(MnemonicTry, try)
+	extend(MnemonicEndTry, endtry)
+	extend(MnemonicThrow, throw)
+	extend(MnemonicConcat, binaryOp(concat))
+	extend(MnemonicStringLength, unaryOp(slen))
+	extend(MnemonicSlice, naryOp(slice, 3))
+	extend(MnemonicIndexOf, binaryOp(indexof))
+	extend(MnemonicSplit, binaryOp(split))
+	extend(MnemonicJoin, binaryOp(join))
+	extend(MnemonicUpper, unaryOp(upper))
+	extend(MnemonicLower, unaryOp(lower))
+	extend(MnemonicTrim, unaryOp(trim))
+	extend(MnemonicReplace, naryOp(replace, 3))
+	extend(MnemonicMatch, matchOp)
+	extend(MnemonicArray, array)
+	extend(MnemonicObject, object)
+	extend(MnemonicGet, binaryOp(get))
+	extend(MnemonicSet, naryOp(set, 3))
+	extend(MnemonicArrayPush, binaryOp(apush))
+	extend(MnemonicArrayPop, apop)
+	extend(MnemonicLength, unaryOp(length))
+	extend(MnemonicKeys, unaryOp(keys))
+	extend(MnemonicValues, unaryOp(values))
+	extend(MnemonicHas, binaryOp(has))
+	extend(MnemonicDelete, binaryOp(del))
+	extend(MnemonicSpread, spread)
+	extend(MnemonicGetPointer, binaryOp(getp))
+	extend(MnemonicSetPointer, setpOp)
+	extend(MnemonicModulo, binaryOp(mod))
+	extend(MnemonicPower, binaryOp(pow))
+	extend(MnemonicIntegerDivide, binaryOp(idiv))
+	extend(MnemonicAbs, unaryOp(mathFunc(math.Abs)))
+	extend(MnemonicFloor, unaryOp(mathFunc(math.Floor)))
+	extend(MnemonicCeil, unaryOp(mathFunc(math.Ceil)))
+	extend(MnemonicRound, unaryOp(mathFunc(round)))
+	extend(MnemonicTrunc, unaryOp(mathFunc(math.Trunc)))
+	extend(MnemonicSqrt, unaryOp(mathFunc(math.Sqrt)))
+	extend(MnemonicLog, unaryOp(mathFunc(math.Log)))
+	extend(MnemonicExp, unaryOp(mathFunc(math.Exp)))
+	extend(MnemonicMin, variadicOp(minimum, 2))
+	extend(MnemonicMax, variadicOp(maximum, 2))
+	extend(MnemonicBitwiseAnd, binaryOp(band))
+	extend(MnemonicBitwiseOr, binaryOp(bor))
+	extend(MnemonicBitwiseXor, binaryOp(bxor))
+	extend(MnemonicBitwiseNot, unaryOp(bnot))
+	extend(MnemonicShiftLeft, binaryOp(shl))
+	extend(MnemonicShiftRight, binaryOp(shr))
+	extend(MnemonicUnsignedShift, binaryOp(ushr))
+	extend(MnemonicTypeOf, unaryOp(typeof))
+	extend(MnemonicToBoolean, unaryOp(tobool))
+	extend(MnemonicToInteger, unaryOp(toint))
+	extend(MnemonicToNumber, unaryOp(tonum))
+	extend(MnemonicToString, unaryOp(tostr))
+	extend(MnemonicIsNull, unaryOp(isType(TypeNull)))
+	extend(MnemonicIsArray, unaryOp(isType(TypeArray)))
+	extend(MnemonicIsObject, unaryOp(isType(TypeObject)))
+	extend(MnemonicParse, parseOp)
+	extend(MnemonicStringify, unaryOp(stringify))
 	return p
 }
 
@@ -76,6 +140,55 @@ func (p *processor) extend(mnemonic Mnemonic, process Process) error {
 	return nil
 }
 
+func (p processor) defined(mnemonic Mnemonic) bool {
+	oc := opcode(mnemonic)
+	return oc < len(p) && p[oc] != nil
+}
+
+func (p processor) override(mnemonic Mnemonic, process Process) error {
+	if process == nil {
+		return errors.New("no process")
+	}
+
+	if !p.defined(mnemonic) {
+		return errors.Errorf("%s not defined", mnemonic)
+	}
+
+	p.replace(mnemonic, process)
+	return nil
+}
+
+func (p processor) remove(mnemonic Mnemonic) error {
+	if !p.defined(mnemonic) {
+		return errors.Errorf("%s not defined", mnemonic)
+	}
+
+	p.replace(mnemonic, nil)
+	return nil
+}
+
+// replace replaces the process of a mnemonic that is already defined.
+func (p processor) replace(mnemonic Mnemonic, process Process) {
+	p[opcode(mnemonic)] = process
+}
+
+func (p processor) mnemonics() []Mnemonic {
+	opcodesMutex.Lock()
+	defer opcodesMutex.Unlock()
+
+	var ms []Mnemonic
+	for m, oc := range opcodes {
+		if oc < len(p) && p[oc] != nil {
+			ms = append(ms, m)
+		}
+	}
+
+	sort.Slice(ms, func(i, j int) bool {
+		return ms[i] < ms[j]
+	})
+	return ms
+}
+
 func (p processor) process(ctx context.Context, inst *Instruction) error {
 	oc := inst.opcode
 	if len(p) <= oc || p[oc] == nil {
@@ -90,6 +203,10 @@ func doPush(ctx context.Context, v Value) error {
 		return err
 	}
 
+	if err := reserveOperands(ctx, 1); err != nil {
+		return err
+	}
+
 	stack.Push(v)
 	return nil
 }
@@ -100,6 +217,10 @@ func doMultiPush(ctx context.Context, vs []Value) error {
 		return err
 	}
 
+	if err := reserveOperands(ctx, len(vs)); err != nil {
+		return err
+	}
+
 	stack.MultiPush(vs)
 	return nil
 }
@@ -130,6 +251,64 @@ func doMultiPop(ctx context.Context, n int) ([]Value, error) {
 	return operands, nil
 }
 
+// doPushSlot is like doPush, but pushes a slot as it is.
+func doPushSlot(ctx context.Context, sl slot) error {
+	stack, err := getOperandStack(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := reserveOperands(ctx, 1); err != nil {
+		return err
+	}
+
+	stack.push(sl)
+	return nil
+}
+
+// doMultiPushSlots is like doMultiPush, but pushes slots as they are.
+func doMultiPushSlots(ctx context.Context, ss []slot) error {
+	stack, err := getOperandStack(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := reserveOperands(ctx, len(ss)); err != nil {
+		return err
+	}
+
+	stack.multiPush(ss)
+	return nil
+}
+
+// doPopSlot is like doPop, but returns the slot of the value without boxing it.
+func doPopSlot(ctx context.Context) (slot, error) {
+	stack, err := getOperandStack(ctx)
+	if err != nil {
+		return slot{}, err
+	}
+
+	sl, err := stack.pop()
+	if err != nil {
+		return slot{}, errors.New("no operand")
+	}
+	return sl, nil
+}
+
+// doMultiPopSlots is like doMultiPop, but returns the slots of the values without boxing them.
+func doMultiPopSlots(ctx context.Context, n int) ([]slot, error) {
+	stack, err := getOperandStack(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	operands, err := stack.multiPop(n)
+	if err != nil {
+		return nil, errors.New("too few operands")
+	}
+	return operands, nil
+}
+
 func doOp(ctx context.Context, op func([]Value) (Value, error), arity int) error {
 	stack, err := GetOperandStack(ctx)
 	if err != nil {
@@ -137,7 +316,10 @@ func doOp(ctx context.Context, op func([]Value) (Value, error), arity int) error
 	}
 
 	if err := stack.Do(op, arity); err != nil {
-		return errors.New("too few operands")
+		if err == errTooFewElements {
+			return errors.New("too few operands")
+		}
+		return err
 	}
 	return nil
 }
@@ -188,7 +370,7 @@ func pop(ctx context.Context, imms []Value) error {
 		return err
 	}
 
-	if _, err := doMultiPop(ctx, n); err != nil {
+	if _, err := doMultiPopSlots(ctx, n); err != nil {
 		return err
 	}
 
@@ -223,45 +405,74 @@ func loadOp(op func(context.Context, Value) (Value, error)) Process {
 	}
 }
 
-func ld(ctx context.Context, v Value) (Value, error) {
-	v, _ = GetGlobalHeap(ctx).Load(ToString(v))
-	return v, nil
+// loadSlotOp is like loadOp, but pushes the slot of the loaded value without boxing it.
+func loadSlotOp(op func(context.Context, Value) (slot, error)) Process {
+	return func(ctx context.Context, imms []Value) error {
+		var v Value
+		var err error
+		if len(imms) > 0 {
+			v = imms[0]
+		} else {
+			v, err = doPop(ctx)
+		}
+		if err != nil {
+			return err
+		}
+
+		sl, err := op(ctx, v)
+		if err != nil {
+			return err
+		}
+
+		if err := doPushSlot(ctx, sl); err != nil {
+			return err
+		}
+
+		GetProgramCounter(ctx).Increment()
+		return nil
+	}
+}
+
+func ld(ctx context.Context, v Value) (slot, error) {
+	return getGlobalHeap(ctx).load(ToString(v)), nil
 }
 
 func lda(ctx context.Context, v Value) (Value, error) {
 	return GetArgument(ctx, ToInteger(v))
 }
 
-func ldl(ctx context.Context, v Value) (Value, error) {
-	lh, err := GetLocalHeap(ctx)
+func ldl(ctx context.Context, v Value) (slot, error) {
+	lh, err := getLocalHeap(ctx)
 	if err != nil {
-		return NullValue(), err
+		return slot{}, err
 	}
 
-	v, _ = lh.Load(ToString(v))
-	return v, nil
+	return lh.load(ToString(v)), nil
 }
 
-func storeOp(op func(context.Context, []Value) error) Process {
+// storeOp stores the slot of a value without boxing it.
+func storeOp(op func(context.Context, string, slot) error) Process {
 	return func(ctx context.Context, imms []Value) error {
-		var vs []Value
-		var err error
+		var k Value
+		var v slot
 		switch len(imms) {
 		case 0:
-			vs, err = doMultiPop(ctx, 2)
+			ss, err := doMultiPopSlots(ctx, 2)
+			if err != nil {
+				return err
+			}
+			k, v = ss[0].value(), ss[1]
 		case 1:
-			vs, err = doMultiPop(ctx, 1)
-			if err == nil {
-				vs = append(vs, imms[0])
+			ss, err := doMultiPopSlots(ctx, 1)
+			if err != nil {
+				return err
 			}
+			k, v = ss[0].value(), toSlot(imms[0])
 		default:
-			vs = imms
-		}
-		if err != nil {
-			return err
+			k, v = imms[0], toSlot(imms[1])
 		}
 
-		if err := op(ctx, vs); err != nil {
+		if err := op(ctx, ToString(k), v); err != nil {
 			return err
 		}
 
@@ -270,19 +481,17 @@ func storeOp(op func(context.Context, []Value) error) Process {
 	}
 }
 
-func st(ctx context.Context, vs []Value) error {
-	GetGlobalHeap(ctx).Store(ToString(vs[0]), vs[1])
-	return nil
+func st(ctx context.Context, k string, v slot) error {
+	return getGlobalHeap(ctx).storeSlot(k, v)
 }
 
-func stl(ctx context.Context, vs []Value) error {
-	lh, err := GetLocalHeap(ctx)
+func stl(ctx context.Context, k string, v slot) error {
+	lh, err := getLocalHeap(ctx)
 	if err != nil {
 		return err
 	}
 
-	lh.Store(ToString(vs[0]), vs[1])
-	return nil
+	return lh.storeSlot(k, v)
 }
 
 func call(ctx context.Context, imms []Value) error {
@@ -296,18 +505,48 @@ func call(ctx context.Context, imms []Value) error {
 		return err
 	}
 
+	return doCall(ctx, addr, argc, nil)
+}
+
+// callv calls the function on the top of the operand stack,
+// which is a function value or the address of a function.
+func callv(ctx context.Context, imms []Value) error {
+	argc, err := getCount(imms, 0, 0)
+	if err != nil {
+		return err
+	}
+
+	v, err := doPop(ctx)
+	if err != nil {
+		return err
+	}
+
+	fn, err := toFunction(ctx, v)
+	if err != nil {
+		return err
+	}
+
+	return doCall(ctx, fn.Address, argc, fn.Env)
+}
+
+func doCall(ctx context.Context, addr, argc int, env Value) error {
 	argv, err := doMultiPop(ctx, argc)
 	if err != nil {
-		return nil
+		return err
+	}
+
+	cs := getCallStack(ctx)
+	if err := getLimits(ctx).checkCallDepth(len(*cs) + 1); err != nil {
+		return err
 	}
 
 	pc := GetProgramCounter(ctx)
 	pc.Increment()
 
-	frame := newFrame()
+	frame := cs.pushFrame()
 	frame.Arguments = argv
 	frame.ReturnTo = pc.Index()
-	getCallStack(ctx).Push(frame)
+	frame.Env = env
 
 	pc.SetIndex(addr)
 	return nil
@@ -319,7 +558,7 @@ func ret(ctx context.Context, imms []Value) error {
 		return err
 	}
 
-	res, err := doMultiPop(ctx, n)
+	res, err := doMultiPopSlots(ctx, n)
 	if err != nil {
 		return err
 	}
@@ -330,15 +569,16 @@ func ret(ctx context.Context, imms []Value) error {
 	}
 
 	GetProgramCounter(ctx).SetIndex(frame.ReturnTo)
-	_, err = getCallStack(ctx).Pop()
-	if err != nil {
+	cs := getCallStack(ctx)
+	if _, err := cs.Pop(); err != nil {
 		return err
 	}
 
-	if err := doMultiPush(ctx, res); err != nil {
-		setResult(ctx, ArrayValue(res))
+	if len(*cs) == 0 {
+		setResult(ctx, ArrayValue(boxSlots(res)))
+		return nil
 	}
-	return nil
+	return doMultiPushSlots(ctx, res)
 }
 
 func jmp(ctx context.Context, imms []Value) error {
@@ -357,12 +597,12 @@ func jt(ctx context.Context, imms []Value) error {
 		return err
 	}
 
-	v, err := doPop(ctx)
+	v, err := doPopSlot(ctx)
 	if err != nil {
 		return err
 	}
 
-	if ToBoolean(v) {
+	if v.toBoolean() {
 		GetProgramCounter(ctx).SetIndex(addr)
 	} else {
 		GetProgramCounter(ctx).Increment()
@@ -376,12 +616,12 @@ func jf(ctx context.Context, imms []Value) error {
 		return err
 	}
 
-	v, err := doPop(ctx)
+	v, err := doPopSlot(ctx)
 	if err != nil {
 		return err
 	}
 
-	if !ToBoolean(v) {
+	if !v.toBoolean() {
 		GetProgramCounter(ctx).SetIndex(addr)
 	} else {
 		GetProgramCounter(ctx).Increment()
@@ -417,6 +657,78 @@ func binaryOp(op func([]Value) (Value, error)) Process {
 	}
 }
 
+// numberOp is like binaryOp, but evaluates the operation fast on two unboxed numbers.
+func numberOp(op func([]Value) (Value, error), fast func(x, y float64) slot) Process {
+	return func(ctx context.Context, imms []Value) error {
+		if len(imms) > 0 {
+			if err := doPush(ctx, imms[0]); err != nil {
+				return err
+			}
+		}
+
+		stack, err := getOperandStack(ctx)
+		if err != nil {
+			return err
+		}
+
+		if !stack.doNumbers(fast) {
+			if err := doOp(ctx, op, 2); err != nil {
+				return err
+			}
+		}
+
+		GetProgramCounter(ctx).Increment()
+		return nil
+	}
+}
+
+// naryOp takes the immediates as the last operands of the operation.
+func naryOp(op func([]Value) (Value, error), arity int) Process {
+	return func(ctx context.Context, imms []Value) error {
+		if err := doMultiPush(ctx, imms); err != nil {
+			return err
+		}
+
+		if err := doOp(ctx, op, arity); err != nil {
+			return err
+		}
+
+		GetProgramCounter(ctx).Increment()
+		return nil
+	}
+}
+
+// variadicOp takes the number of operands as an immediate.
+func variadicOp(op func([]Value) (Value, error), arity int) Process {
+	return func(ctx context.Context, imms []Value) error {
+		n, err := getCount(imms, 0, 0)
+		if err != nil {
+			return err
+		}
+
+		if len(imms) == 0 {
+			n = arity
+		}
+
+		vs, err := doMultiPop(ctx, n)
+		if err != nil {
+			return err
+		}
+
+		v, err := op(vs)
+		if err != nil {
+			return err
+		}
+
+		if err := doPush(ctx, v); err != nil {
+			return err
+		}
+
+		GetProgramCounter(ctx).Increment()
+		return nil
+	}
+}
+
 func eq(vs []Value) (Value, error) {
 	return BooleanValue(Equal(vs[0], vs[1])), nil
 }
@@ -502,41 +814,84 @@ func loadStoreOp(op func(ctx context.Context, v Value) error) Process {
 }
 
 func inc(ctx context.Context, v Value) error {
-	h := GetGlobalHeap(ctx)
+	h := getGlobalHeap(ctx)
 	k := ToString(v)
-	v, _ = h.Load(k)
-	h.Store(k, NumberValue(ToNumber(v)+1.0))
-	return nil
+	return h.storeSlot(k, numberSlot(h.load(k).toNumber()+1.0))
 }
 
 func incl(ctx context.Context, v Value) error {
-	lh, err := GetLocalHeap(ctx)
+	lh, err := getLocalHeap(ctx)
 	if err != nil {
 		return err
 	}
 
 	k := ToString(v)
-	v, _ = lh.Load(k)
-	lh.Store(k, NumberValue(ToNumber(v)+1.0))
-	return nil
+	return lh.storeSlot(k, numberSlot(lh.load(k).toNumber()+1.0))
 }
 
 func dec(ctx context.Context, v Value) error {
-	h := GetGlobalHeap(ctx)
+	h := getGlobalHeap(ctx)
 	k := ToString(v)
-	v, _ = h.Load(k)
-	h.Store(k, NumberValue(ToNumber(v)-1.0))
-	return nil
+	return h.storeSlot(k, numberSlot(h.load(k).toNumber()-1.0))
 }
 
 func decl(ctx context.Context, v Value) error {
-	lh, err := GetLocalHeap(ctx)
+	lh, err := getLocalHeap(ctx)
 	if err != nil {
 		return err
 	}
 
 	k := ToString(v)
-	v, _ = lh.Load(k)
-	lh.Store(k, NumberValue(ToNumber(v)-1.0))
+	return lh.storeSlot(k, numberSlot(lh.load(k).toNumber()-1.0))
+}
+
+func try(ctx context.Context, imms []Value) error {
+	addr, err := getAddress(imms, 0)
+	if err != nil {
+		return err
+	}
+
+	frame, err := getFrame(ctx)
+	if err != nil {
+		return err
+	}
+
+	frame.Handlers = append(frame.Handlers, &handler{
+		Address: addr,
+		Depth:   frame.Operands.len(),
+	})
+
+	GetProgramCounter(ctx).Increment()
+	return nil
+}
+
+func endtry(ctx context.Context, imms []Value) error {
+	frame, err := getFrame(ctx)
+	if err != nil {
+		return err
+	}
+
+	l := len(frame.Handlers)
+	if l == 0 {
+		return errors.New("no handler")
+	}
+	frame.Handlers = frame.Handlers[:l-1]
+
+	GetProgramCounter(ctx).Increment()
 	return nil
 }
+
+func throw(ctx context.Context, imms []Value) error {
+	var v Value
+	var err error
+	if len(imms) > 0 {
+		v = imms[0]
+	} else {
+		v, err = doPop(ctx)
+	}
+	if err != nil {
+		return err
+	}
+
+	return &ThrownError{Value: v}
+}
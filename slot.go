@@ -0,0 +1,117 @@
+package jsm
+
+import "math"
+
+// slot is the representation of a value in operand stacks and heaps.
+// It holds a float64 unboxed in n, so that arithmetic and counters on numbers
+// do not allocate memory for their results, and holds any other value as it is in v.
+// A value is boxed again only when it leaves the machine as a Value.
+type slot struct {
+	v Value
+	n float64
+}
+
+// unboxed is the type of v of the slots holding numbers in n.
+type unboxed struct{}
+
+func toSlot(v Value) slot {
+	if f, ok := v.(float64); ok {
+		return slot{v: unboxed{}, n: f}
+	}
+	return slot{v: v}
+}
+
+func numberSlot(f float64) slot {
+	return slot{v: unboxed{}, n: f}
+}
+
+func booleanSlot(b bool) slot {
+	return slot{v: BooleanValue(b)}
+}
+
+// value returns the value held by the slot.
+func (s slot) value() Value {
+	if _, ok := s.v.(unboxed); ok {
+		return NumberValue(s.n)
+	}
+	return s.v
+}
+
+// number returns the unboxed number of the slot, or false if it does not hold one.
+func (s slot) number() (float64, bool) {
+	if _, ok := s.v.(unboxed); ok {
+		return s.n, true
+	}
+	return 0.0, false
+}
+
+func (s slot) toNumber() float64 {
+	if _, ok := s.v.(unboxed); ok {
+		return s.n
+	}
+	return ToNumber(s.v)
+}
+
+func (s slot) toBoolean() bool {
+	if _, ok := s.v.(unboxed); ok {
+		return s.n != 0.0 && !math.IsNaN(s.n)
+	}
+	return ToBoolean(s.v)
+}
+
+// toSlots returns the slots of values.
+func toSlots(vs []Value) []slot {
+	ss := make([]slot, len(vs))
+	for i, v := range vs {
+		ss[i] = toSlot(v)
+	}
+	return ss
+}
+
+// boxSlots returns the values held by slots.
+func boxSlots(ss []slot) []Value {
+	vs := make([]Value, len(ss))
+	for i, sl := range ss {
+		vs[i] = sl.value()
+	}
+	return vs
+}
+
+// These functions are the operations on two unboxed numbers used by numberOp,
+// which give the same results as the corresponding operations on values.
+
+func addNumbers(x, y float64) slot {
+	return numberSlot(x + y)
+}
+
+func subNumbers(x, y float64) slot {
+	return numberSlot(x - y)
+}
+
+func mulNumbers(x, y float64) slot {
+	return numberSlot(x * y)
+}
+
+func eqNumbers(x, y float64) slot {
+	return booleanSlot(x == y)
+}
+
+func neNumbers(x, y float64) slot {
+	return booleanSlot(x != y)
+}
+
+func gtNumbers(x, y float64) slot {
+	return booleanSlot(x > y)
+}
+
+func geNumbers(x, y float64) slot {
+	return booleanSlot(x >= y)
+}
+
+func ltNumbers(x, y float64) slot {
+	return booleanSlot(x < y)
+}
+
+func leNumbers(x, y float64) slot {
+	return booleanSlot(x <= y)
+}
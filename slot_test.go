@@ -0,0 +1,98 @@
+package jsm
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSlot(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, v := range []Value{
+		NullValue(), BooleanValue(true), IntegerValue(3), NumberValue(1.5),
+		NumberValue(1e10), NumberValue(math.Copysign(0, -1)), StringValue("x"),
+	} {
+		assert.Equal(v, toSlot(v).value())
+	}
+
+	_, ok := toSlot(IntegerValue(3)).number()
+	assert.False(ok)
+	f, ok := toSlot(NumberValue(1e10)).number()
+	assert.True(ok)
+	assert.Equal(1e10, f)
+
+	assert.True(math.Signbit(ToNumber(toSlot(NumberValue(math.Copysign(0, -1))).value())))
+	assert.False(numberSlot(math.NaN()).toBoolean())
+	assert.True(numberSlot(-1.0).toBoolean())
+}
+
+func TestNumberOps(t *testing.T) {
+	assert := assert.New(t)
+
+	ops := []struct {
+		op   func([]Value) (Value, error)
+		fast func(x, y float64) slot
+	}{
+		{eq, eqNumbers}, {ne, neNumbers}, {gt, gtNumbers}, {ge, geNumbers},
+		{lt, ltNumbers}, {le, leNumbers}, {add, addNumbers}, {sub, subNumbers}, {mul, mulNumbers},
+	}
+	fs := []float64{0, math.Copysign(0, -1), 1, -2.5, 1e10, math.Inf(1), math.NaN()}
+
+	for _, o := range ops {
+		for _, x := range fs {
+			for _, y := range fs {
+				v, err := o.op([]Value{x, y})
+				assert.NoError(err)
+
+				w := o.fast(x, y).value()
+				if f, ok := v.(float64); ok && math.IsNaN(f) {
+					assert.True(math.IsNaN(w.(float64)))
+					continue
+				}
+				assert.Equal(v, w)
+			}
+		}
+	}
+}
+
+func TestStackSlots(t *testing.T) {
+	assert := assert.New(t)
+
+	s := newStack()
+	s.Push(NumberValue(1e10))
+	s.Push(IntegerValue(2))
+	assert.False(s.doNumbers(addNumbers))
+
+	vs, err := s.MultiPop(2)
+	assert.NoError(err)
+	assert.Equal([]Value{NumberValue(1e10), IntegerValue(2)}, vs)
+
+	s.MultiPush(vs)
+	ws, err := s.MultiPop(1)
+	assert.NoError(err)
+	assert.Equal([]Value{IntegerValue(2)}, ws)
+	assert.Equal([]Value{NumberValue(1e10), IntegerValue(2)}, vs)
+
+	s.Push(NumberValue(3.0))
+	assert.True(s.doNumbers(addNumbers))
+	assert.Equal([]Value{NumberValue(1e10 + 3.0)}, s.toValues())
+}
+
+func TestMachineUnboxedNumbers(t *testing.T) {
+	assert := assert.New(t)
+
+	p := loadExample(t, "sum_of_series.json")
+	m := NewMachine()
+	args := []Value{NumberValue(10000.0)}
+
+	res, err := m.Run(p, args)
+	assert.NoError(err)
+	assert.Equal([]Value{NumberValue(50005000.0)}, res)
+
+	allocs := testing.AllocsPerRun(10, func() {
+		m.Run(p, args)
+	})
+	assert.Less(allocs, 1000.0)
+}
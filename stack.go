@@ -32,70 +32,158 @@ type Stack interface {
 	Do(op func([]Value) (Value, error), arity int) error
 }
 
-type stack []Value
+var errTooFewElements = errors.New("too few elements")
+
+// stack holds its values in slots, and keeps the values popped by MultiPop and Do
+// in values at the same indices as the slots, so that they are not overwritten
+// until the slots are pushed again.
+type stack struct {
+	slots  []slot
+	values []Value
+}
 
 func newStack() *stack {
-	s := make(stack, 0, 10)
-	return &s
+	return &stack{slots: make([]slot, 0, 10)}
 }
 
 func (s *stack) Push(v Value) {
-	*s = append(*s, v)
+	s.slots = append(s.slots, toSlot(v))
 }
 
 func (s *stack) MultiPush(vs []Value) {
-	*s = append(*s, vs...)
+	for _, v := range vs {
+		s.slots = append(s.slots, toSlot(v))
+	}
 }
 
 func (s *stack) Pop() (Value, error) {
-	l := len(*s)
-	if l == 0 {
-		return NullValue(), errors.New("empty stack")
+	sl, err := s.pop()
+	if err != nil {
+		return NullValue(), err
 	}
-
-	v := (*s)[l-1]
-	*s = (*s)[:l-1]
-	return v, nil
+	return sl.value(), nil
 }
 
 func (s *stack) MultiPop(n int) ([]Value, error) {
-	l := len(*s)
+	l := len(s.slots)
 	if l < n {
 		return nil, errors.New("too few elements")
 	}
 
-	vs := (*s)[l-n:]
-	*s = (*s)[:l-n]
+	vs := s.box(l-n, l)
+	s.slots = s.slots[:l-n]
 	return vs, nil
 }
 
 func (s *stack) Peek() (Value, error) {
-	l := len(*s)
+	l := len(s.slots)
 	if l == 0 {
 		return NullValue(), errors.New("empty stack")
 	}
 
-	return (*s)[l-1], nil
+	return s.slots[l-1].value(), nil
 }
 
 func (s *stack) Do(op func([]Value) (Value, error), arity int) error {
-	l := len(*s)
+	l := len(s.slots)
 	if l < arity {
-		return errors.New("too few elements")
+		return errTooFewElements
 	}
 
-	v, err := op((*s)[l-arity:])
+	v, err := op(s.box(l-arity, l))
 	if err != nil {
 		return err
 	}
 
-	(*s)[l-arity] = v
-	*s = (*s)[:l-arity+1]
+	s.slots[l-arity] = toSlot(v)
+	s.slots = s.slots[:l-arity+1]
 	return nil
 }
 
+// box returns the values of the slots in the range [i, j).
+func (s *stack) box(i, j int) []Value {
+	if len(s.values) < j {
+		s.values = append(s.values, make([]Value, j-len(s.values))...)
+	}
+
+	vs := s.values[i:j]
+	for k := range vs {
+		vs[k] = s.slots[i+k].value()
+	}
+	return vs
+}
+
+func (s *stack) push(sl slot) {
+	s.slots = append(s.slots, sl)
+}
+
+func (s *stack) pop() (slot, error) {
+	l := len(s.slots)
+	if l == 0 {
+		return slot{}, errors.New("empty stack")
+	}
+
+	sl := s.slots[l-1]
+	s.slots = s.slots[:l-1]
+	return sl, nil
+}
+
+// multiPop pops multiple slots from the stack.
+// The returned slice can be modified by push.
+func (s *stack) multiPop(n int) ([]slot, error) {
+	l := len(s.slots)
+	if l < n {
+		return nil, errTooFewElements
+	}
+
+	ss := s.slots[l-n:]
+	s.slots = s.slots[:l-n]
+	return ss, nil
+}
+
+// doNumbers evaluates an operation on the two numbers at the top of the stack.
+// It reports false without doing anything unless both of them are unboxed numbers.
+func (s *stack) doNumbers(op func(x, y float64) slot) bool {
+	l := len(s.slots)
+	if l < 2 {
+		return false
+	}
+
+	x, ok := s.slots[l-2].number()
+	if !ok {
+		return false
+	}
+	y, ok := s.slots[l-1].number()
+	if !ok {
+		return false
+	}
+
+	s.slots[l-2] = op(x, y)
+	s.slots = s.slots[:l-1]
+	return true
+}
+
+func (s *stack) len() int {
+	return len(s.slots)
+}
+
+func (s *stack) truncate(n int) {
+	if len(s.slots) > n {
+		s.slots = s.slots[:n]
+	}
+}
+
+func (s *stack) multiPush(ss []slot) {
+	s.slots = append(s.slots, ss...)
+}
+
+// toValues returns a copy of the values on the stack.
+func (s *stack) toValues() []Value {
+	return boxSlots(s.slots)
+}
+
 func (s *stack) Clear() {
-	*s = (*s)[:0]
+	s.slots = s.slots[:0]
 }
 
 func (s *stack) Dump() ([]byte, error) {
@@ -106,3 +194,20 @@ func (s *stack) Dump() ([]byte, error) {
 func (s *stack) Restore(data []byte) error {
 	return errors.Wrap(json.Unmarshal(data, s), "failed to restore stack")
 }
+
+func (s *stack) MarshalJSON() ([]byte, error) {
+	return json.Marshal(escapeValues(s.toValues()))
+}
+
+func (s *stack) UnmarshalJSON(data []byte) error {
+	var vs []Value
+	if err := json.Unmarshal(data, &vs); err != nil {
+		return err
+	}
+
+	s.slots = s.slots[:0]
+	for _, v := range vs {
+		s.slots = append(s.slots, toSlot(restoreFunctions(v)))
+	}
+	return nil
+}
@@ -0,0 +1,212 @@
+package jsm
+
+import (
+	"context"
+	"reflect"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+)
+
+// The string instructions convert their operands with ToString,
+// and count the positions and lengths of strings in characters (runes).
+
+func concat(vs []Value) (Value, error) {
+	return StringValue(ToString(vs[0]) + ToString(vs[1])), nil
+}
+
+func slen(vs []Value) (Value, error) {
+	return IntegerValue(utf8.RuneCountInString(ToString(vs[0]))), nil
+}
+
+// slice extracts the characters between start and end like String.prototype.slice.
+// Negative positions count from the end of the string, and a null end means the end.
+func slice(vs []Value) (Value, error) {
+	rs := []rune(ToString(vs[0]))
+	l := len(rs)
+
+	start := relativePosition(ToInteger(vs[1]), l)
+	end := l
+	if TypeOf(vs[2]) != TypeNull {
+		end = relativePosition(ToInteger(vs[2]), l)
+	}
+
+	if start >= end {
+		return StringValue(""), nil
+	}
+	return StringValue(string(rs[start:end])), nil
+}
+
+func relativePosition(pos, l int) int {
+	if pos < 0 {
+		pos += l
+		if pos < 0 {
+			return 0
+		}
+	}
+
+	if pos > l {
+		return l
+	}
+	return pos
+}
+
+func indexof(vs []Value) (Value, error) {
+	s := ToString(vs[0])
+	i := strings.Index(s, ToString(vs[1]))
+	if i < 0 {
+		return IntegerValue(-1), nil
+	}
+	return IntegerValue(utf8.RuneCountInString(s[:i])), nil
+}
+
+func split(vs []Value) (Value, error) {
+	ss := strings.Split(ToString(vs[0]), ToString(vs[1]))
+	a := make([]Value, len(ss))
+	for i, s := range ss {
+		a[i] = StringValue(s)
+	}
+	return ArrayValue(a), nil
+}
+
+func join(vs []Value) (Value, error) {
+	if TypeOf(vs[0]) != TypeArray {
+		return NullValue(), errors.New("not an array")
+	}
+
+	val := reflect.ValueOf(vs[0])
+	ss := make([]string, val.Len())
+	for i := range ss {
+		ss[i] = ToString(val.Index(i).Interface())
+	}
+	return StringValue(strings.Join(ss, ToString(vs[1]))), nil
+}
+
+func upper(vs []Value) (Value, error) {
+	return StringValue(strings.ToUpper(ToString(vs[0]))), nil
+}
+
+func lower(vs []Value) (Value, error) {
+	return StringValue(strings.ToLower(ToString(vs[0]))), nil
+}
+
+func trim(vs []Value) (Value, error) {
+	return StringValue(strings.TrimSpace(ToString(vs[0]))), nil
+}
+
+// replace replaces all the occurrences of a substring.
+func replace(vs []Value) (Value, error) {
+	return StringValue(strings.Replace(ToString(vs[0]), ToString(vs[1]), ToString(vs[2]), -1)), nil
+}
+
+// match returns the leftmost match of a regular expression and its submatches,
+// or null if there is no match.
+func match(vs []Value) (Value, error) {
+	re, err := regexp.Compile(ToString(vs[1]))
+	if err != nil {
+		return NullValue(), errors.New("invalid regular expression")
+	}
+	return submatch(re, vs[0]), nil
+}
+
+// matchOp is binaryOp(match) that compiles the pattern given as an immediate only once.
+func matchOp(ctx context.Context, imms []Value) error {
+	if len(imms) == 0 {
+		return matchOperands(ctx, imms)
+	}
+
+	re, err := compileImmediate(ctx, ToString(imms[0]))
+	if err != nil {
+		return err
+	}
+
+	v, err := doPop(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := doPush(ctx, submatch(re, v)); err != nil {
+		return err
+	}
+
+	GetProgramCounter(ctx).Increment()
+	return nil
+}
+
+var matchOperands = binaryOp(match)
+
+func compileImmediate(ctx context.Context, pattern string) (*regexp.Regexp, error) {
+	regexps := getRegexps(ctx)
+	if re, ok := regexps[pattern]; ok {
+		return re, nil
+	}
+
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, errors.New("invalid regular expression")
+	}
+
+	regexps[pattern] = re
+	return re, nil
+}
+
+func submatch(re *regexp.Regexp, v Value) Value {
+	ss := re.FindStringSubmatch(ToString(v))
+	if ss == nil {
+		return NullValue()
+	}
+
+	a := make([]Value, len(ss))
+	for i, s := range ss {
+		a[i] = StringValue(s)
+	}
+	return ArrayValue(a)
+}
+
+func atMostTwoStrings(ctx context.Context, imms []Value) ([]Value, error) {
+	if len(imms) > 2 {
+		return nil, preprocessingError(ctx, imms, "too many immediates")
+	}
+
+	var vs []Value
+	for _, imm := range imms {
+		vs = append(vs, StringValue(ToString(imm)))
+	}
+	return vs, nil
+}
+
+// immediatesOfSlice normalizes the start and end positions of slice.
+// A single immediate is the start position like the argument of slice(start) in JavaScript.
+func immediatesOfSlice(ctx context.Context, imms []Value) ([]Value, error) {
+	if len(imms) > 2 {
+		return nil, preprocessingError(ctx, imms, "too many immediates")
+	}
+
+	var vs []Value
+	for i, imm := range imms {
+		if TypeOf(imm) == TypeNull && i == 1 {
+			vs = append(vs, NullValue())
+		} else {
+			vs = append(vs, IntegerValue(ToInteger(imm)))
+		}
+	}
+
+	if len(vs) == 1 {
+		vs = append(vs, NullValue())
+	}
+	return vs, nil
+}
+
+func immediatesOfMatch(ctx context.Context, imms []Value) ([]Value, error) {
+	vs, err := atMostOneString(ctx, imms)
+	if err != nil || len(vs) == 0 {
+		return vs, err
+	}
+
+	if _, err := regexp.Compile(ToString(vs[0])); err != nil {
+		return nil, preprocessingError(ctx, imms, "invalid regular expression")
+	}
+	return vs, nil
+}
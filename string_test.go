@@ -0,0 +1,131 @@
+package jsm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringOperations(t *testing.T) {
+	assert := assert.New(t)
+
+	tests := []struct {
+		op  func([]Value) (Value, error)
+		vs  []Value
+		res Value
+	}{
+		{concat, []Value{StringValue("a"), StringValue("b")}, StringValue("ab")},
+		{concat, []Value{StringValue("a"), NumberValue(1.0)}, StringValue("a1")},
+		{concat, []Value{NullValue(), BooleanValue(true)}, StringValue("nulltrue")},
+		{slen, []Value{StringValue("日本語")}, IntegerValue(3)},
+		{slen, []Value{NumberValue(1.5)}, IntegerValue(3)},
+		{slice, []Value{StringValue("abcde"), IntegerValue(1), IntegerValue(3)}, StringValue("bc")},
+		{slice, []Value{StringValue("abcde"), IntegerValue(-2), NullValue()}, StringValue("de")},
+		{slice, []Value{StringValue("abcde"), IntegerValue(-9), IntegerValue(9)}, StringValue("abcde")},
+		{slice, []Value{StringValue("abcde"), IntegerValue(3), IntegerValue(1)}, StringValue("")},
+		{slice, []Value{StringValue("日本語"), IntegerValue(1), NullValue()}, StringValue("本語")},
+		{indexof, []Value{StringValue("日本語"), StringValue("語")}, IntegerValue(2)},
+		{indexof, []Value{StringValue("abc"), StringValue("d")}, IntegerValue(-1)},
+		{split, []Value{StringValue("a,b,c"), StringValue(",")},
+			ArrayValue([]Value{StringValue("a"), StringValue("b"), StringValue("c")})},
+		{split, []Value{StringValue("ab"), StringValue("")},
+			ArrayValue([]Value{StringValue("a"), StringValue("b")})},
+		{join, []Value{ArrayValue([]Value{StringValue("a"), NumberValue(1.0)}), StringValue("-")}, StringValue("a-1")},
+		{join, []Value{[]string{"a", "b"}, StringValue("")}, StringValue("ab")},
+		{upper, []Value{StringValue("aBc")}, StringValue("ABC")},
+		{lower, []Value{StringValue("aBc")}, StringValue("abc")},
+		{trim, []Value{StringValue(" \ta b\n")}, StringValue("a b")},
+		{replace, []Value{StringValue("a-b-c"), StringValue("-"), StringValue("+")}, StringValue("a+b+c")},
+		{match, []Value{StringValue("key=value"), StringValue(`(\w+)=(\w+)`)},
+			ArrayValue([]Value{StringValue("key=value"), StringValue("key"), StringValue("value")})},
+		{match, []Value{StringValue("abc"), StringValue(`\d`)}, NullValue()},
+	}
+
+	for _, test := range tests {
+		res, err := test.op(test.vs)
+		assert.NoError(err)
+		assert.Equal(test.res, res, "%v", test.vs)
+	}
+
+	_, err := join([]Value{StringValue("a"), StringValue(",")})
+	assert.EqualError(err, "not an array")
+
+	_, err = match([]Value{StringValue("a"), StringValue("(")})
+	assert.EqualError(err, "invalid regular expression")
+}
+
+func TestStringInstructions(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{StringValue("s")}},
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicTrim},
+		{Mnemonic: MnemonicSplit, Immediates: []Value{StringValue(" ")}},
+		{Mnemonic: MnemonicJoin, Immediates: []Value{StringValue("_")}},
+		{Mnemonic: MnemonicUpper},
+		{Mnemonic: MnemonicConcat, Immediates: []Value{StringValue("!")}},
+		{Mnemonic: MnemonicReplace, Immediates: []Value{StringValue("_"), StringValue("-")}},
+		{Mnemonic: MnemonicSlice, Immediates: []Value{IntegerValue(1), NullValue()}},
+		{Mnemonic: MnemonicStoreLocal},
+		{Mnemonic: MnemonicLoadLocal, Immediates: []Value{StringValue("s")}},
+		{Mnemonic: MnemonicStringLength},
+		{Mnemonic: MnemonicLoadLocal, Immediates: []Value{StringValue("s")}},
+		{Mnemonic: MnemonicIndexOf, Immediates: []Value{StringValue("-")}},
+		{Mnemonic: MnemonicLoadLocal, Immediates: []Value{StringValue("s")}},
+		{Mnemonic: MnemonicMatch, Immediates: []Value{StringValue(`-(\w)`)}},
+		{Mnemonic: MnemonicLoadLocal, Immediates: []Value{StringValue("s")}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(4)}},
+	}
+
+	m := NewMachine()
+	res, err := m.Run(p, []Value{StringValue(" hello big world ")})
+	assert.NoError(err)
+	assert.Equal([]Value{
+		IntegerValue(15),
+		IntegerValue(4),
+		ArrayValue([]Value{StringValue("-B"), StringValue("B")}),
+		StringValue("ELLO-BIG-WORLD!"),
+	}, res)
+
+	_, err = m.Run([]Instruction{
+		{Mnemonic: MnemonicMatch, Immediates: []Value{StringValue("(")}},
+	}, nil)
+	assert.Error(err)
+}
+
+func TestStringImmediates(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicSlice, Immediates: []Value{IntegerValue(2)}},
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicSlice, Immediates: []Value{IntegerValue(-3), IntegerValue(-1)}},
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicMatch, Immediates: []Value{StringValue(`c(\w)`)}},
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{StringValue(`^\w`)}},
+		{Mnemonic: MnemonicMatch},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(4)}},
+	}
+
+	m := NewMachine()
+	for i := 0; i < 2; i++ {
+		res, err := m.Run(p, []Value{StringValue("abcde")})
+		assert.NoError(err)
+		assert.Equal([]Value{
+			StringValue("cde"),
+			StringValue("cd"),
+			ArrayValue([]Value{StringValue("cd"), StringValue("d")}),
+			ArrayValue([]Value{StringValue("a")}),
+		}, res)
+	}
+
+	regexps := getRegexps(m.(*machine).context)
+	assert.Len(regexps, 1)
+	assert.Contains(regexps, `c(\w)`)
+
+	m.Clear()
+	assert.Empty(getRegexps(m.(*machine).context))
+}
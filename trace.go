@@ -0,0 +1,35 @@
+package jsm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+type tracer struct {
+	NopHook
+	w io.Writer
+}
+
+func (t *tracer) BeforeInstruction(ctx context.Context, pc int, inst *Instruction, operands []Value) {
+	data, _ := json.Marshal(operands)
+	fmt.Fprintf(t.w, "%d\t%d\t%s\t%s\n", len(*getCallStack(ctx)), pc, formatInstruction(inst), data)
+}
+
+func formatInstruction(inst *Instruction) string {
+	imms := make([]string, len(inst.Immediates))
+	for i, imm := range inst.Immediates {
+		data, err := json.Marshal(imm)
+		if err != nil {
+			data = []byte(ToString(imm))
+		}
+		imms[i] = string(data)
+	}
+
+	if len(imms) == 0 {
+		return string(inst.Mnemonic)
+	}
+	return string(inst.Mnemonic) + " " + strings.Join(imms, ", ")
+}
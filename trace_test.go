@@ -0,0 +1,24 @@
+package jsm
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTrace(t *testing.T) {
+	assert := assert.New(t)
+
+	var buf bytes.Buffer
+	m := NewMachine(WithTrace(&buf))
+	_, err := m.Run([]Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1), StringValue("a")}},
+		{Mnemonic: MnemonicPop},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	}, nil)
+	assert.NoError(err)
+	assert.Equal("1\t0\tpush 1, \"a\"\t[]\n"+
+		"1\t1\tpop\t[1,\"a\"]\n"+
+		"1\t2\tret 1\t[1]\n", buf.String())
+}
@@ -16,4 +16,26 @@ const (
 	// TypePointer is a special type that represents a pointer to an arbitrary type,
 	// but is always marshaled as 'null' in JSON.
 	TypePointer
+
+	// TypeFunction is a special type that represents a reference to a function of JSM.
+	TypeFunction
 )
+
+var typeNames = [...]string{
+	TypeUndefined: "undefined",
+	TypeNull:      "null",
+	TypeBoolean:   "boolean",
+	TypeNumber:    "number",
+	TypeString:    "string",
+	TypeArray:     "array",
+	TypeObject:    "object",
+	TypePointer:   "pointer",
+	TypeFunction:  "function",
+}
+
+func (t Type) String() string {
+	if t < 0 || int(t) >= len(typeNames) {
+		return typeNames[TypeUndefined]
+	}
+	return typeNames[t]
+}
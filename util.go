@@ -32,6 +32,19 @@ func floatToInt(f float64) int {
 	return int(sign * math.Floor(math.Abs(f)))
 }
 
+// floatToInt64 truncates f and wraps it modulo 2^64.
+func floatToInt64(f float64) int64 {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return 0
+	}
+
+	f = math.Mod(math.Trunc(f), 1<<64)
+	if f < 0 {
+		return -int64(uint64(-f))
+	}
+	return int64(uint64(f))
+}
+
 func floatToString(f float64) string {
 	if math.IsInf(f, 0) {
 		if math.Signbit(f) {
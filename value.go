@@ -36,9 +36,29 @@ func IntegerValue(i int) Value {
 
 // NumberValue returns the number value representing the specified float64.
 func NumberValue(f float64) Value {
+	// return an already allocated number value for a small integer to avoid new memory allocation
+	if f >= minSmallNumber && f <= maxSmallNumber {
+		i := int(f)
+		if float64(i) == f && (i != 0 || !math.Signbit(f)) {
+			return smallNumbers[i-minSmallNumber]
+		}
+	}
 	return f
 }
 
+const (
+	minSmallNumber = -128
+	maxSmallNumber = 1023
+)
+
+var smallNumbers = func() []Value {
+	vs := make([]Value, maxSmallNumber-minSmallNumber+1)
+	for i := range vs {
+		vs[i] = float64(i + minSmallNumber)
+	}
+	return vs
+}()
+
 // StringValue returns the string value representing the specified string.
 func StringValue(s string) Value {
 	return s
@@ -64,6 +84,32 @@ func PointerValue(p unsafe.Pointer) Value {
 
 // TypeOf returns the type of the given value.
 func TypeOf(v Value) Type {
+	switch v := v.(type) {
+	case nil:
+		return TypeNull
+	case float64, int:
+		return TypeNumber
+	case string:
+		return TypeString
+	case bool:
+		return TypeBoolean
+	case []Value:
+		if v == nil {
+			return TypeNull
+		}
+		return TypeArray
+	case map[string]Value:
+		if v == nil {
+			return TypeNull
+		}
+		return TypeObject
+	case *Function:
+		if v == nil {
+			return TypeNull
+		}
+		return TypeFunction
+	}
+
 	val := reflect.ValueOf(v)
 	switch val.Kind() {
 	case reflect.Invalid:
@@ -98,6 +144,19 @@ func TypeOf(v Value) Type {
 
 // ToBoolean converts the given value to a boolean.
 func ToBoolean(v Value) bool {
+	switch v := v.(type) {
+	case nil:
+		return false
+	case bool:
+		return v
+	case float64:
+		return v != 0.0 && !math.IsNaN(v)
+	case int:
+		return v != 0
+	case string:
+		return v != ""
+	}
+
 	val := reflect.ValueOf(v)
 	switch val.Kind() {
 	case reflect.Invalid:
@@ -122,6 +181,13 @@ func ToBoolean(v Value) bool {
 
 // ToInteger converts the given value to an integer.
 func ToInteger(v Value) int {
+	switch v := v.(type) {
+	case float64:
+		return floatToInt(v)
+	case int:
+		return v
+	}
+
 	val := reflect.ValueOf(v)
 	switch val.Kind() {
 	case reflect.Bool:
@@ -146,8 +212,50 @@ func ToInteger(v Value) int {
 	}
 }
 
+// ToInt32 converts the given value to a signed 32-bit integer
+// in the same way as ToInt32 of ECMAScript.
+func ToInt32(v Value) int32 {
+	return int32(toInt64(v))
+}
+
+// ToUint32 converts the given value to an unsigned 32-bit integer
+// in the same way as ToUint32 of ECMAScript.
+func ToUint32(v Value) uint32 {
+	return uint32(toInt64(v))
+}
+
+// toInt64 converts the given value to an integer modulo 2^64.
+// Unlike ToNumber, it is exact for all 64-bit integers.
+func toInt64(v Value) int64 {
+	switch v := v.(type) {
+	case float64:
+		return floatToInt64(v)
+	case int:
+		return int64(v)
+	}
+
+	val := reflect.ValueOf(v)
+	switch val.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return val.Int()
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return int64(val.Uint())
+	default:
+		return floatToInt64(ToNumber(v))
+	}
+}
+
 // ToNumber converts the given value to a floating point number.
 func ToNumber(v Value) float64 {
+	switch v := v.(type) {
+	case float64:
+		return v
+	case int:
+		return float64(v)
+	case nil:
+		return 0.0
+	}
+
 	val := reflect.ValueOf(v)
 	switch val.Kind() {
 	case reflect.Invalid:
@@ -181,6 +289,13 @@ func ToNumber(v Value) float64 {
 
 // ToString converts the given value to a string.
 func ToString(v Value) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return floatToString(v)
+	}
+
 	val := reflect.ValueOf(v)
 	switch val.Kind() {
 	case reflect.Float32, reflect.Float64:
@@ -216,95 +331,207 @@ func ToPointer(v Value) unsafe.Pointer {
 }
 
 // Equal checks if the given two values are equivalent.
+// Integers beyond 2^53 are compared exactly, and are never equal to floats.
 func Equal(v1, v2 Value) bool {
+	if f1, ok := fastNumber(v1); ok {
+		if f2, ok := fastNumber(v2); ok {
+			return f1 == f2
+		}
+	}
+
+	switch v1 := v1.(type) {
+	case nil:
+		if v2 == nil {
+			return true
+		}
+	case string:
+		if s2, ok := v2.(string); ok {
+			return v1 == s2
+		}
+	case bool:
+		if b2, ok := v2.(bool); ok {
+			return v1 == b2
+		}
+	}
+
 	return reflect.DeepEqual(normalize(v1), normalize(v2))
 }
 
 // Less checks if v1 is less than v2.
+// Numbers are compared exactly even if they are 64-bit integers beyond 2^53.
 func Less(v1, v2 Value) bool {
-	val1 := reflect.ValueOf(normalize(v1))
-	val2 := reflect.ValueOf(normalize(v2))
-	kind1 := val1.Kind()
-	kind2 := val2.Kind()
-	switch kind1 {
-	case reflect.Bool:
-		if kind2 == reflect.Bool {
-			return !val1.Bool() && val2.Bool()
+	if f1, ok := fastNumber(v1); ok {
+		if f2, ok := fastNumber(v2); ok {
+			return f1 < f2
 		}
-		return false
-	case reflect.Int64:
-		switch kind2 {
-		case reflect.Int64:
-			return val1.Int() < val2.Int()
-		case reflect.Uint64:
-			// val1 < 0 && val2 >= 0
-			return true
-		case reflect.Float64:
-			return float64(val1.Int()) < val2.Float()
-		default:
-			return false
-		}
-	case reflect.Uint64:
-		switch kind2 {
-		case reflect.Int64:
-			// val1 >= 0 && val2 < 0
-			return false
-		case reflect.Uint64:
-			return val1.Uint() < val2.Uint()
-		case reflect.Float64:
-			return float64(val1.Uint()) < val2.Float()
-		default:
-			return false
-		}
-	case reflect.Float64:
-		switch kind2 {
-		case reflect.Int64:
-			return val1.Float() < float64(val2.Int())
-		case reflect.Uint64:
-			return val1.Float() < float64(val2.Uint())
-		case reflect.Float64:
-			return val1.Float() < val2.Float()
-		default:
-			return false
+	}
+
+	if s1, ok := v1.(string); ok {
+		if s2, ok := v2.(string); ok {
+			return s1 < s2
 		}
-	case reflect.String:
-		if kind2 == reflect.String {
-			return strings.Compare(val1.String(), val2.String()) < 0
+	}
+
+	n1 := normalize(v1)
+	n2 := normalize(v2)
+	if c, ok := compareNumbers(n1, n2); ok {
+		return c < 0
+	}
+
+	switch n1 := n1.(type) {
+	case bool:
+		if b2, ok := n2.(bool); ok {
+			return !n1 && b2
 		}
-		return false
+	case string:
+		if s2, ok := n2.(string); ok {
+			return strings.Compare(n1, s2) < 0
+		}
+	}
+	return false
+}
+
+// compareNumbers compares two normalized numbers exactly and returns -1, 0 or 1.
+// It returns false if either of them is not a number or is NaN.
+func compareNumbers(n1, n2 Value) (int, bool) {
+	switch n1 := n1.(type) {
+	case float64:
+		switch n2 := n2.(type) {
+		case float64:
+			return compareFloats(n1, n2)
+		case int64:
+			c, ok := compareFloatInt(n1, n2)
+			return c, ok
+		case uint64:
+			c, ok := compareFloatUint(n1, n2)
+			return c, ok
+		}
+	case int64:
+		switch n2 := n2.(type) {
+		case float64:
+			c, ok := compareFloatInt(n2, n1)
+			return -c, ok
+		case int64:
+			return compareInts(n1 < n2, n1 > n2), true
+		case uint64:
+			// n1 < 0 && n2 >= 0
+			return -1, true
+		}
+	case uint64:
+		switch n2 := n2.(type) {
+		case float64:
+			c, ok := compareFloatUint(n2, n1)
+			return -c, ok
+		case int64:
+			// n1 >= 0 && n2 < 0
+			return 1, true
+		case uint64:
+			return compareInts(n1 < n2, n1 > n2), true
+		}
+	}
+	return 0, false
+}
+
+func compareFloats(f1, f2 float64) (int, bool) {
+	if math.IsNaN(f1) || math.IsNaN(f2) {
+		return 0, false
+	}
+	return compareInts(f1 < f2, f1 > f2), true
+}
+
+// compareFloatInt compares f with i without rounding i to a float64.
+func compareFloatInt(f float64, i int64) (int, bool) {
+	switch {
+	case math.IsNaN(f):
+		return 0, false
+	case f >= 1<<63:
+		return 1, true
+	case f < -1<<63:
+		return -1, true
+	}
+
+	t := math.Trunc(f)
+	if ti := int64(t); ti != i {
+		return compareInts(ti < i, ti > i), true
+	}
+	return compareInts(f < t, f > t), true
+}
+
+// compareFloatUint compares f with u without rounding u to a float64.
+func compareFloatUint(f float64, u uint64) (int, bool) {
+	switch {
+	case math.IsNaN(f):
+		return 0, false
+	case f >= 1<<64:
+		return 1, true
+	case f < 0:
+		return -1, true
+	}
+
+	t := math.Trunc(f)
+	if tu := uint64(t); tu != u {
+		return compareInts(tu < u, tu > u), true
+	}
+	return compareInts(f < t, f > t), true
+}
+
+func compareInts(less, greater bool) int {
+	switch {
+	case less:
+		return -1
+	case greater:
+		return 1
 	default:
-		return false
+		return 0
 	}
 }
 
 const maxSafeInteger = 1<<53 - 1
 
+// fastNumber returns the float64 of a number value that can be compared without reflection.
+func fastNumber(v Value) (float64, bool) {
+	switch v := v.(type) {
+	case float64:
+		return v, true
+	case int:
+		if v >= -maxSafeInteger && v <= maxSafeInteger {
+			return float64(v), true
+		}
+	}
+	return 0.0, false
+}
+
+// normalize converts a value into its canonical representation for comparison.
+// Numbers become float64 if they are safe integers or floats,
+// and int64 or uint64 otherwise, so that large integers are kept exact.
 func normalize(v Value) Value {
+	switch v.(type) {
+	case nil, float64, string, bool:
+		return v
+	}
+
 	val := reflect.ValueOf(v)
-	kind := val.Kind()
-	switch kind {
+	switch val.Kind() {
+	case reflect.Bool:
+		return val.Bool()
 	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
 		i := val.Int()
 		if i >= -maxSafeInteger && i <= maxSafeInteger {
 			return float64(i)
 		} else if i >= 0 {
 			return uint64(i)
-		} else if kind != reflect.Int64 {
-			return i
 		}
-		return v
+		return i
 	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
 		u := val.Uint()
 		if u <= maxSafeInteger {
 			return float64(u)
-		} else if kind != reflect.Uint64 {
-			return u
 		}
-		return v
-	case reflect.Float32:
+		return u
+	case reflect.Float32, reflect.Float64:
 		return val.Float()
-	case reflect.Float64:
-		return v
+	case reflect.String:
+		return val.String()
 	case reflect.Slice:
 		if val.IsNil() {
 			return nil
@@ -29,6 +29,24 @@ func TestType(t *testing.T) {
 	assert.Equal(TypePointer, TypeOf(PointerValue(unsafe.Pointer(assert))))
 }
 
+func TestNumberValue(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, f := range []float64{-129, -128, -1, 0, 1, 1023, 1024, 0.5, math.NaN(), math.Inf(1)} {
+		v, ok := NumberValue(f).(float64)
+		assert.True(ok)
+		assert.True(v == f || math.IsNaN(f))
+	}
+
+	v := NumberValue(math.Copysign(0, -1)).(float64)
+	assert.True(math.Signbit(v))
+	assert.False(math.Signbit(NumberValue(0).(float64)))
+
+	assert.Equal(0.0, testing.AllocsPerRun(10, func() {
+		NumberValue(1023)
+	}))
+}
+
 func TestToBoolean(t *testing.T) {
 	assert := assert.New(t)
 
@@ -109,6 +127,26 @@ func TestToInteger(t *testing.T) {
 	assert.Equal(minInt, ToInteger(StringValue("-Infinity")))
 }
 
+func TestToInt32(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.Equal(int32(0), ToInt32(NullValue()))
+	assert.Equal(int32(1), ToInt32(BooleanValue(true)))
+	assert.Equal(int32(1), ToInt32(NumberValue(1.9)))
+	assert.Equal(int32(-1), ToInt32(NumberValue(-1.9)))
+	assert.Equal(int32(0), ToInt32(NumberValue(math.NaN())))
+	assert.Equal(int32(0), ToInt32(NumberValue(math.Inf(1))))
+	assert.Equal(int32(-2147483648), ToInt32(NumberValue(2147483648.0)))
+	assert.Equal(int32(1), ToInt32(NumberValue(4294967297.0)))
+	assert.Equal(int32(-1), ToInt32(NumberValue(-4294967297.0)))
+	assert.Equal(int32(-1), ToInt32(uint64(math.MaxUint64)))
+	assert.Equal(int32(12), ToInt32(StringValue("12.5")))
+
+	assert.Equal(uint32(4294967295), ToUint32(IntegerValue(-1)))
+	assert.Equal(uint32(2147483648), ToUint32(NumberValue(2147483648.0)))
+	assert.Equal(uint32(0), ToUint32(StringValue("a")))
+}
+
 func TestToString(t *testing.T) {
 	assert := assert.New(t)
 
@@ -164,6 +202,10 @@ func TestEqual(t *testing.T) {
 	assert.True(Equal(NumberValue(math.Inf(1)), NumberValue(math.Inf(1))))
 	assert.True(Equal(NumberValue(math.Inf(-1)), NumberValue(math.Inf(-1))))
 	assert.True(Equal(IntegerValue(9007199254740991), NumberValue(9007199254740991.0)))
+	assert.True(Equal(IntegerValue(1<<53+1), uint64(1<<53+1)))
+	assert.True(Equal(int64(1<<60), uint64(1<<60)))
+	assert.True(Equal(uint64(1<<63+1), uint64(1<<63+1)))
+	assert.True(Equal(int64(-1<<63), IntegerValue(-1<<63)))
 	assert.True(Equal(StringValue("a"), StringValue("a")))
 	assert.True(Equal(
 		ArrayValue([]Value{IntegerValue(123), StringValue("abc")}),
@@ -181,7 +223,11 @@ func TestEqual(t *testing.T) {
 	assert.False(Equal(NumberValue(1.0), NumberValue(1.1)))
 	assert.False(Equal(NumberValue(math.NaN()), NumberValue(math.NaN())))
 	assert.False(Equal(IntegerValue(9007199254740992), NumberValue(9007199254740992.0)))
+	assert.False(Equal(uint64(1<<63+1), int64(-1<<63+1)))
+	assert.False(Equal(uint64(1<<63+1), uint64(1<<63)))
 	assert.False(Equal(StringValue("a"), StringValue("b")))
+	assert.False(Equal(StringValue("1"), IntegerValue(1)))
+	assert.False(Equal(BooleanValue(true), IntegerValue(1)))
 	assert.False(Equal(
 		ArrayValue([]Value{IntegerValue(123), StringValue("abc")}),
 		ArrayValue([]Value{NumberValue(1.23), StringValue("abc")})))
@@ -204,6 +250,16 @@ func TestLess(t *testing.T) {
 	assert.True(Less(NumberValue(1.0), NumberValue(1.1)))
 	assert.True(Less(NumberValue(math.Inf(-1)), NumberValue(math.Inf(1))))
 	assert.True(Less(StringValue("a"), StringValue("b")))
+	assert.True(Less(IntegerValue(1), NumberValue(1.5)))
+	assert.True(Less(int8(-1), NumberValue(0)))
+	assert.True(Less(NumberValue(1<<53), IntegerValue(1<<53+1)))
+	assert.True(Less(IntegerValue(1<<53+1), NumberValue(1<<53+2)))
+	assert.True(Less(NumberValue(1<<63), uint64(1<<63+1)))
+	assert.True(Less(uint64(1<<63), NumberValue(1<<63+1<<11)))
+	assert.True(Less(int64(-1<<63), IntegerValue(-1<<63+1)))
+	assert.True(Less(int64(-1<<63+1), uint64(1<<63+1)))
+	assert.True(Less(uint64(1<<63+1), uint64(1<<63+2)))
+	assert.True(Less(uint64(math.MaxUint64), NumberValue(math.Inf(1))))
 
 	assert.False(Less(NullValue(), NullValue()))
 	assert.False(Less(NullValue(), BooleanValue(false)))
@@ -226,6 +282,13 @@ func TestLess(t *testing.T) {
 	assert.False(Less(NumberValue(math.Inf(1)), NumberValue(math.Inf(-1))))
 	assert.False(Less(StringValue("a"), StringValue("a")))
 	assert.False(Less(StringValue("b"), StringValue("a")))
+	assert.False(Less(StringValue("0"), IntegerValue(1)))
+	assert.False(Less(IntegerValue(1<<53+1), NumberValue(1<<53)))
+	assert.False(Less(uint64(1<<63+1), NumberValue(1<<63)))
+	assert.False(Less(NumberValue(1<<63), uint64(1<<63)))
+	assert.False(Less(uint64(1<<63), NumberValue(1<<63)))
+	assert.False(Less(uint64(1<<63+1), int64(-1<<63+1)))
+	assert.False(Less(uint64(1<<63+1), NumberValue(math.NaN())))
 }
 
 func TestMarshalPointer(t *testing.T) {
@@ -239,3 +302,24 @@ func TestMarshalPointer(t *testing.T) {
 	assert.NoError(err)
 	assert.Equal([]byte("null"), data)
 }
+
+func BenchmarkToNumber(b *testing.B) {
+	vs := []Value{NumberValue(1.5), IntegerValue(2), int32(3)}
+	for i := 0; i < b.N; i++ {
+		ToNumber(vs[i%len(vs)])
+	}
+}
+
+func BenchmarkEqual(b *testing.B) {
+	vs := []Value{NumberValue(1.5), IntegerValue(2), StringValue("a")}
+	for i := 0; i < b.N; i++ {
+		Equal(vs[i%len(vs)], vs[(i+1)%len(vs)])
+	}
+}
+
+func BenchmarkLess(b *testing.B) {
+	vs := []Value{NumberValue(1.5), IntegerValue(2), StringValue("a")}
+	for i := 0; i < b.N; i++ {
+		Less(vs[i%len(vs)], vs[(i+1)%len(vs)])
+	}
+}
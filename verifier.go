@@ -0,0 +1,421 @@
+package jsm
+
+import (
+	"sort"
+
+	"github.com/pkg/errors"
+)
+
+// stackEffect returns the numbers of operands that an instruction pops and pushes.
+type stackEffect func(imms []Value) (pops, pushes int, err error)
+
+var stackEffects = map[Mnemonic]stackEffect{
+	MnemonicNop:            fixedEffect(0, 0),
+	MnemonicPush:           effectOfPush,
+	MnemonicPop:            effectOfPop,
+	MnemonicLoad:           effectOfLoad,
+	MnemonicLoadArgument:   effectOfLoad,
+	MnemonicLoadLocal:      effectOfLoad,
+	MnemonicStore:          effectOfStore,
+	MnemonicStoreLocal:     effectOfStore,
+	MnemonicEqual:          effectOfBinaryOp,
+	MnemonicNotEqual:       effectOfBinaryOp,
+	MnemonicGreaterThan:    effectOfBinaryOp,
+	MnemonicGreaterOrEqual: effectOfBinaryOp,
+	MnemonicLessThan:       effectOfBinaryOp,
+	MnemonicLessOrEqual:    effectOfBinaryOp,
+	MnemonicNot:            fixedEffect(1, 1),
+	MnemonicAnd:            effectOfBinaryOp,
+	MnemonicOr:             effectOfBinaryOp,
+	MnemonicNeg:            fixedEffect(1, 1),
+	MnemonicAdd:            effectOfBinaryOp,
+	MnemonicSubtract:       effectOfBinaryOp,
+	MnemonicMultiply:       effectOfBinaryOp,
+	MnemonicDivide:         effectOfBinaryOp,
+	MnemonicIncrement:      effectOfLoadStore,
+	MnemonicIncrementLocal: effectOfLoadStore,
+	MnemonicDecrement:      effectOfLoadStore,
+	MnemonicDecrementLocal: effectOfLoadStore,
+	MnemonicLoadFunction:   fixedEffect(0, 1),
+	MnemonicClosure:        effectOfClosure,
+	MnemonicLoadCaptured:   effectOfLoad,
+	MnemonicEndTry:         fixedEffect(0, 0),
+	MnemonicConcat:         effectOfBinaryOp,
+	MnemonicStringLength:   fixedEffect(1, 1),
+	MnemonicSlice:          effectOfOp(3),
+	MnemonicIndexOf:        effectOfBinaryOp,
+	MnemonicSplit:          effectOfBinaryOp,
+	MnemonicJoin:           effectOfBinaryOp,
+	MnemonicUpper:          fixedEffect(1, 1),
+	MnemonicLower:          fixedEffect(1, 1),
+	MnemonicTrim:           fixedEffect(1, 1),
+	MnemonicReplace:        effectOfOp(3),
+	MnemonicMatch:          effectOfBinaryOp,
+	MnemonicArray:          effectOfVariadicOp(0),
+	MnemonicObject:         effectOfObject,
+	MnemonicGet:            effectOfBinaryOp,
+	MnemonicSet:            effectOfOp(3),
+	MnemonicArrayPush:      effectOfBinaryOp,
+	MnemonicArrayPop:       fixedEffect(1, 2),
+	MnemonicLength:         fixedEffect(1, 1),
+	MnemonicKeys:           fixedEffect(1, 1),
+	MnemonicValues:         fixedEffect(1, 1),
+	MnemonicHas:            effectOfBinaryOp,
+	MnemonicDelete:         effectOfBinaryOp,
+	MnemonicSpread:         effectOfSpread,
+	MnemonicGetPointer:     effectOfBinaryOp,
+	MnemonicSetPointer:     effectOfOp(3),
+	MnemonicModulo:         effectOfBinaryOp,
+	MnemonicPower:          effectOfBinaryOp,
+	MnemonicIntegerDivide:  effectOfBinaryOp,
+	MnemonicAbs:            fixedEffect(1, 1),
+	MnemonicFloor:          fixedEffect(1, 1),
+	MnemonicCeil:           fixedEffect(1, 1),
+	MnemonicRound:          fixedEffect(1, 1),
+	MnemonicTrunc:          fixedEffect(1, 1),
+	MnemonicSqrt:           fixedEffect(1, 1),
+	MnemonicLog:            fixedEffect(1, 1),
+	MnemonicExp:            fixedEffect(1, 1),
+	MnemonicMin:            effectOfVariadicOp(2),
+	MnemonicMax:            effectOfVariadicOp(2),
+	MnemonicBitwiseAnd:     effectOfBinaryOp,
+	MnemonicBitwiseOr:      effectOfBinaryOp,
+	MnemonicBitwiseXor:     effectOfBinaryOp,
+	MnemonicBitwiseNot:     fixedEffect(1, 1),
+	MnemonicShiftLeft:      effectOfBinaryOp,
+	MnemonicShiftRight:     effectOfBinaryOp,
+	MnemonicUnsignedShift:  effectOfBinaryOp,
+	MnemonicTypeOf:         fixedEffect(1, 1),
+	MnemonicToBoolean:      fixedEffect(1, 1),
+	MnemonicToInteger:      fixedEffect(1, 1),
+	MnemonicToNumber:       fixedEffect(1, 1),
+	MnemonicToString:       fixedEffect(1, 1),
+	MnemonicIsNull:         fixedEffect(1, 1),
+	MnemonicIsArray:        fixedEffect(1, 1),
+	MnemonicIsObject:       fixedEffect(1, 1),
+	MnemonicParse:          fixedEffect(1, 1),
+	MnemonicStringify:      fixedEffect(1, 1),
+}
+
+func fixedEffect(pops, pushes int) stackEffect {
+	return func(imms []Value) (int, int, error) {
+		return pops, pushes, nil
+	}
+}
+
+func effectOfPush(imms []Value) (int, int, error) {
+	return 0, len(imms), nil
+}
+
+func effectOfPop(imms []Value) (int, int, error) {
+	n, err := getCount(imms, 0, 1)
+	return n, 0, err
+}
+
+func effectOfLoad(imms []Value) (int, int, error) {
+	if len(imms) > 0 {
+		return 0, 1, nil
+	}
+	return 1, 1, nil
+}
+
+func effectOfClosure(imms []Value) (int, int, error) {
+	n, err := getCount(imms, 1, 0)
+	return n, 1, err
+}
+
+func effectOfStore(imms []Value) (int, int, error) {
+	switch len(imms) {
+	case 0:
+		return 2, 0, nil
+	case 1:
+		return 1, 0, nil
+	default:
+		return 0, 0, nil
+	}
+}
+
+func effectOfBinaryOp(imms []Value) (int, int, error) {
+	if len(imms) > 0 {
+		return 1, 1, nil
+	}
+	return 2, 1, nil
+}
+
+func effectOfOp(arity int) stackEffect {
+	return func(imms []Value) (int, int, error) {
+		return arity - len(imms), 1, nil
+	}
+}
+
+func effectOfVariadicOp(arity int) stackEffect {
+	return func(imms []Value) (int, int, error) {
+		if len(imms) > 0 {
+			n, err := getCount(imms, 0, 0)
+			return n, 1, err
+		}
+		return arity, 1, nil
+	}
+}
+
+func effectOfObject(imms []Value) (int, int, error) {
+	n, err := getCount(imms, 0, 0)
+	return 2 * n, 1, err
+}
+
+func effectOfSpread(imms []Value) (int, int, error) {
+	n, err := getCount(imms, 0, 0)
+	return 1, n, err
+}
+
+func effectOfLoadStore(imms []Value) (int, int, error) {
+	if len(imms) > 0 {
+		return 0, 0, nil
+	}
+	return 1, 0, nil
+}
+
+// These constants are the states of the result of a function.
+const (
+	resultPending = -1
+	resultOpaque  = -2
+)
+
+// verification is the level of the verification of programs.
+type verification int
+
+// These constants are the levels of verification.
+// Labels and addresses are always verified.
+const (
+	// verifyAddresses checks only that all addresses are in the program.
+	verifyAddresses verification = iota
+
+	// verifyStack also checks that no instruction pops more operands than the stack has
+	// and that all returns of a function return the same number of values.
+	verifyStack
+
+	// verifyStrict also checks that the depth of the operand stack at each instruction
+	// is the same on all paths.
+	verifyStrict
+)
+
+// verifier verifies a preprocessed program.
+// It checks that all addresses are in the program, and computes the depth of
+// the operand stack along all control flow paths of each function, that is,
+// the code reachable from the beginning of the program or the address of a call,
+// to ensure that no instruction pops more operands than the stack has on any path,
+// and that all returns of a function return the same number of values.
+// Where paths with different depths merge, the smallest depth is assumed
+// unless the verification is strict.
+// Instructions between try and endtry must not pop the operands below the depth saved by try.
+// Functions containing instructions with unknown stack effects, such as callv,
+// are not verified.
+type verifier struct {
+	program  []Instruction
+	effects  map[Mnemonic]stackEffect
+	strict   bool
+	results  map[int]int
+	complete map[int]bool
+}
+
+// verify verifies a program using the given stack effects of instructions,
+// which take precedence over those of the built-in instructions.
+// A nil stack effect means that the stack effect is unknown.
+func verify(program []Instruction, effects map[Mnemonic]stackEffect, level verification) error {
+	v := &verifier{
+		program:  program,
+		effects:  effects,
+		strict:   level >= verifyStrict,
+		results:  map[int]int{0: resultPending},
+		complete: map[int]bool{},
+	}
+
+	for idx, inst := range program {
+		switch v.builtin(inst.Mnemonic) {
+		case MnemonicJump, MnemonicJumpIfTrue, MnemonicJumpIfFalse, MnemonicTry:
+			if _, err := checkAddress(program, idx); err != nil {
+				return err
+			}
+		case MnemonicCall, MnemonicLoadFunction, MnemonicClosure:
+			addr, err := checkAddress(program, idx)
+			if err != nil {
+				return err
+			}
+			v.results[addr] = resultPending
+		}
+	}
+
+	if len(program) == 0 || level < verifyStack {
+		return nil
+	}
+
+	entries := make([]int, 0, len(v.results))
+	for entry := range v.results {
+		entries = append(entries, entry)
+	}
+	sort.Ints(entries)
+
+	for changed := true; changed; {
+		changed = false
+		for _, entry := range entries {
+			if v.complete[entry] {
+				continue
+			}
+
+			res, complete, err := v.analyze(entry)
+			if err != nil {
+				return err
+			}
+
+			if res != v.results[entry] || complete {
+				v.results[entry] = res
+				v.complete[entry] = complete
+				changed = true
+			}
+		}
+	}
+	return nil
+}
+
+// builtin returns the mnemonic if the instruction is built-in, or an empty mnemonic otherwise,
+// so that the control flow of overridden or removed instructions is not assumed.
+func (v *verifier) builtin(mnemonic Mnemonic) Mnemonic {
+	if _, ok := v.effects[mnemonic]; ok {
+		return ""
+	}
+	return mnemonic
+}
+
+func checkAddress(program []Instruction, idx int) (int, error) {
+	imms := program[idx].Immediates
+	if len(imms) == 0 {
+		return -1, errors.Errorf("address missing at %d", idx)
+	}
+
+	addr := ToInteger(imms[0])
+	if addr < 0 || addr >= len(program) {
+		return -1, errors.Errorf("address out of range at %d: %d", idx, addr)
+	}
+	return addr, nil
+}
+
+// analyze computes the depths of the operand stack in the function
+// beginning at entry, and returns the number of values it returns.
+// Paths through calls of functions whose results are pending are not followed,
+// in which case the analysis is reported as incomplete.
+func (v *verifier) analyze(entry int) (int, bool, error) {
+	depths := map[int]int{entry: 0}
+	// floors are the depths saved by the active try instructions.
+	floors := map[int][]int{}
+	queue := []int{entry}
+	result := resultPending
+	complete := true
+
+	next := func(idx, depth int, fs []int) error {
+		if idx >= len(v.program) {
+			return nil
+		}
+
+		if d, ok := depths[idx]; ok {
+			if d != depth && v.strict {
+				return errors.Errorf("inconsistent stack depth at %d: %d and %d", idx, d, depth)
+			}
+			if d <= depth {
+				return nil
+			}
+		}
+
+		depths[idx] = depth
+		floors[idx] = fs
+		queue = append(queue, idx)
+		return nil
+	}
+
+	for len(queue) > 0 {
+		idx := queue[0]
+		queue = queue[1:]
+
+		inst := &v.program[idx]
+		mnemonic := v.builtin(inst.Mnemonic)
+		depth := depths[idx]
+		fs := floors[idx]
+		branchFloors := fs
+
+		var pops, pushes, branchDepth int
+		var terminal bool
+		var err error
+		branch := -1
+		switch mnemonic {
+		case MnemonicJump:
+			branch, branchDepth = ToInteger(inst.Immediates[0]), depth
+			terminal = true
+		case MnemonicJumpIfTrue, MnemonicJumpIfFalse:
+			pops = 1
+			branch, branchDepth = ToInteger(inst.Immediates[0]), depth-1
+		case MnemonicCall:
+			pops, err = getCount(inst.Immediates, 1, 0)
+			pushes = v.results[ToInteger(inst.Immediates[0])]
+			if pushes == resultOpaque {
+				return resultOpaque, true, nil
+			}
+			if pushes == resultPending {
+				terminal = true
+				complete = false
+			}
+		case MnemonicTry:
+			branch, branchDepth = ToInteger(inst.Immediates[0]), depth+1
+			fs = append(fs[:len(fs):len(fs)], depth)
+		case MnemonicEndTry:
+			if len(fs) > 0 {
+				fs = fs[:len(fs)-1]
+			}
+		case MnemonicThrow:
+			pops, _, _ = effectOfLoadStore(inst.Immediates)
+			terminal = true
+		case MnemonicReturn:
+			pops, err = getCount(inst.Immediates, 0, 0)
+			terminal = true
+		default:
+			effect, ok := v.effects[inst.Mnemonic]
+			if !ok {
+				effect, ok = stackEffects[inst.Mnemonic]
+			}
+			if !ok || effect == nil {
+				return resultOpaque, true, nil
+			}
+			pops, pushes, err = effect(inst.Immediates)
+		}
+		if err != nil {
+			return result, false, errors.Errorf("%v at %d: %s", err, idx, inst.Mnemonic)
+		}
+
+		if depth < pops {
+			return result, false, errors.Errorf("stack underflow at %d: %s needs %d operands but has %d", idx, inst.Mnemonic, pops, depth)
+		}
+
+		if l := len(fs); l > 0 && depth-pops < fs[l-1] &&
+			mnemonic != MnemonicReturn && mnemonic != MnemonicThrow {
+			return result, false, errors.Errorf("stack underflow at %d: %s pops operands saved by try", idx, inst.Mnemonic)
+		}
+
+		if mnemonic == MnemonicReturn {
+			if result != resultPending && result != pops {
+				return result, false, errors.Errorf("inconsistent number of return values at %d: %d and %d", idx, result, pops)
+			}
+			result = pops
+		}
+
+		if branch >= 0 {
+			if err := next(branch, branchDepth, branchFloors); err != nil {
+				return result, false, err
+			}
+		}
+
+		if !terminal {
+			if err := next(idx+1, depth-pops+pushes, fs); err != nil {
+				return result, false, err
+			}
+		}
+	}
+	return result, complete, nil
+}
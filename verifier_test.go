@@ -0,0 +1,167 @@
+package jsm
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func preprocessAndVerify(program []Instruction) error {
+	p, err := newPreprocessor().preprocess(program)
+	if err != nil {
+		return err
+	}
+	return verify(p, nil, verifyStrict)
+}
+
+func TestVerify(t *testing.T) {
+	assert := assert.New(t)
+
+	assert.NoError(preprocessAndVerify(loadExample(t, "fibonacci.json")))
+	assert.NoError(preprocessAndVerify(loadExample(t, "sum_of_series.json")))
+	assert.NoError(preprocessAndVerify([]Instruction{}))
+
+	assert.NoError(preprocessAndVerify([]Instruction{
+		{Mnemonic: MnemonicCall, Immediates: []Value{StringValue("two")}},
+		{Mnemonic: MnemonicAdd},
+		{Mnemonic: MnemonicCall, Immediates: []Value{StringValue("loop"), IntegerValue(1)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		{Label: "two", Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1), IntegerValue(2)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(2)}},
+		{Label: "loop", Mnemonic: MnemonicCall, Immediates: []Value{StringValue("loop")}},
+	}))
+
+	assert.NoError(preprocessAndVerify([]Instruction{
+		{Mnemonic: MnemonicCall, Immediates: []Value{StringValue("f")}},
+		{Mnemonic: MnemonicPop, Immediates: []Value{IntegerValue(5)}},
+		{Label: "f", Mnemonic: "unknown"},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	}))
+}
+
+func TestVerifyErrors(t *testing.T) {
+	assert := assert.New(t)
+
+	err := preprocessAndVerify([]Instruction{
+		{Mnemonic: MnemonicJump, Immediates: []Value{IntegerValue(2)}},
+		{Mnemonic: MnemonicReturn},
+	})
+	assert.EqualError(err, "address out of range at 0: 2")
+
+	err = preprocessAndVerify([]Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicAdd},
+	})
+	assert.EqualError(err, "stack underflow at 1: add needs 2 operands but has 1")
+
+	err = preprocessAndVerify([]Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicCall, Immediates: []Value{StringValue("f"), IntegerValue(2)}},
+		{Label: "f", Mnemonic: MnemonicReturn},
+	})
+	assert.EqualError(err, "stack underflow at 1: call needs 2 operands but has 1")
+
+	err = preprocessAndVerify([]Instruction{
+		{Mnemonic: MnemonicCall, Immediates: []Value{StringValue("f")}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		{Label: "f", Mnemonic: MnemonicReturn},
+	})
+	assert.EqualError(err, "stack underflow at 1: ret needs 1 operands but has 0")
+
+	err = preprocessAndVerify([]Instruction{
+		{Label: "loop", Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicJump, Immediates: []Value{StringValue("loop")}},
+	})
+	assert.EqualError(err, "inconsistent stack depth at 0: 0 and 1")
+
+	err = preprocessAndVerify([]Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1), IntegerValue(2)}},
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicJumpIfTrue, Immediates: []Value{StringValue("one")}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(2)}},
+		{Label: "one", Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+	})
+	assert.EqualError(err, "inconsistent number of return values at 3: 1 and 2")
+}
+
+func TestVerifyAfterCall(t *testing.T) {
+	assert := assert.New(t)
+
+	err := preprocessAndVerify([]Instruction{
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicJumpIfTrue, Immediates: []Value{StringValue("one")}},
+		{Mnemonic: MnemonicCall, Immediates: []Value{StringValue("f")}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(2)}},
+		{Label: "one", Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(1)}},
+		{Label: "f", Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1), IntegerValue(2)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(2)}},
+	})
+	assert.EqualError(err, "inconsistent number of return values at 3: 1 and 2")
+}
+
+func TestVerifyLevels(t *testing.T) {
+	assert := assert.New(t)
+
+	p, err := newPreprocessor().preprocess([]Instruction{
+		{Label: "loop", Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicJump, Immediates: []Value{StringValue("loop")}},
+	})
+	assert.NoError(err)
+	assert.NoError(verify(p, nil, verifyAddresses))
+	assert.NoError(verify(p, nil, verifyStack))
+	assert.EqualError(verify(p, nil, verifyStrict), "inconsistent stack depth at 0: 0 and 1")
+
+	p, err = newPreprocessor().preprocess([]Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1), IntegerValue(2)}},
+		{Label: "loop", Mnemonic: MnemonicPop},
+		{Mnemonic: MnemonicJump, Immediates: []Value{StringValue("loop")}},
+	})
+	assert.NoError(err)
+	assert.NoError(verify(p, nil, verifyAddresses))
+	assert.EqualError(verify(p, nil, verifyStack), "stack underflow at 1: pop needs 1 operands but has 0")
+}
+
+func TestVerifyInvalidCount(t *testing.T) {
+	assert := assert.New(t)
+
+	for _, inst := range []Instruction{
+		{Mnemonic: MnemonicPop, Immediates: []Value{IntegerValue(0)}},
+		{Mnemonic: MnemonicObject, Immediates: []Value{IntegerValue(-1)}},
+		{Mnemonic: MnemonicArray, Immediates: []Value{IntegerValue(-2)}},
+		{Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(-1)}},
+	} {
+		err := verify([]Instruction{inst}, nil, verifyStack)
+		assert.EqualError(err, "invalid count at 0: "+string(inst.Mnemonic))
+	}
+}
+
+func TestMachineVerification(t *testing.T) {
+	assert := assert.New(t)
+
+	p := []Instruction{
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1)}},
+		{Mnemonic: MnemonicAdd},
+	}
+
+	_, err := NewMachine(WithoutVerification()).Run(p, nil)
+	assert.EqualError(err, "add at 1: too few operands")
+
+	_, err = NewMachine().Run(p, nil)
+	assert.EqualError(err, "stack underflow at 1: add needs 2 operands but has 1")
+
+	p = []Instruction{
+		{Mnemonic: MnemonicLoadArgument, Immediates: []Value{IntegerValue(0)}},
+		{Label: "loop", Mnemonic: MnemonicJumpIfFalse, Immediates: []Value{StringValue("end")}},
+		{Mnemonic: MnemonicPush, Immediates: []Value{IntegerValue(1), BooleanValue(false)}},
+		{Mnemonic: MnemonicJump, Immediates: []Value{StringValue("loop")}},
+		{Label: "end", Mnemonic: MnemonicReturn, Immediates: []Value{IntegerValue(0)}},
+	}
+
+	res, err := NewMachine().Run(p, []Value{BooleanValue(true)})
+	assert.NoError(err)
+	assert.Equal([]Value{}, res)
+
+	_, err = NewMachine(WithStrictVerification()).Run(p, []Value{BooleanValue(true)})
+	assert.EqualError(err, "inconsistent stack depth at 1: 1 and 2")
+}